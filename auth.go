@@ -1,23 +1,39 @@
 package flex
 
+import (
+	"sync"
+)
+
 // Auth ...
 type Auth interface {
 	clearAll()
 	getHandlers() []string
+	discover() map[string]HandlerMetadata
+	hasHandler(taskName string) bool
 	process(task *Task, modules Modules) (*Task, *Task)
 	resolve(taskName string) func(context *Request, complete AuthCompletionHandler, modules Modules) (*Task, *Task)
 	Register(taskName string, functionToExecute func(context *Request, complete AuthCompletionHandler, modules Modules) (*Task, *Task))
+	SetRateLimit(taskName string, limit RateLimit)
+	Describe(taskName string, metadata HandlerMetadata)
 }
 
 type auth struct {
+	mu            sync.RWMutex
 	authFunctions map[string]func(context *Request, complete AuthCompletionHandler, modules Modules) (*Task, *Task)
+	options       map[string]handlerOptions
+	middleware    *middlewareStack
 }
 
 func (fa *auth) clearAll() {
+	fa.mu.Lock()
 	fa.authFunctions = make(map[string]func(req *Request, complete AuthCompletionHandler, modules Modules) (*Task, *Task))
+	fa.mu.Unlock()
 }
 
 func (fa *auth) getHandlers() []string {
+	fa.mu.RLock()
+	defer fa.mu.RUnlock()
+
 	keys := make([]string, 0)
 	for key := range fa.authFunctions {
 		keys = append(keys, key)
@@ -25,28 +41,90 @@ func (fa *auth) getHandlers() []string {
 	return keys
 }
 
+func (fa *auth) discover() map[string]HandlerMetadata {
+	fa.mu.RLock()
+	defer fa.mu.RUnlock()
+
+	metadata := make(map[string]HandlerMetadata)
+	for taskName := range fa.authFunctions {
+		metadata[taskName] = fa.options[taskName].describe()
+	}
+	return metadata
+}
+
+func (fa *auth) hasHandler(taskName string) bool {
+	fa.mu.RLock()
+	defer fa.mu.RUnlock()
+
+	_, ok := fa.authFunctions[taskName]
+	return ok
+}
+
 func (fa *auth) process(task *Task, modules Modules) (*Task, *Task) {
-	authCompletionHandler := NewAuthCompletionHandler(task)
-	requestCompletionHandler := authCompletionHandler
 	authHandler := fa.resolve(task.TaskName)
-	return authHandler(&task.Request, requestCompletionHandler, modules)
+
+	// middleware shares the KinveyCompletionHandler signature, so the auth
+	// handler is adapted to run at the centre of the chain
+	handler := fa.middleware.wrap(fa.handlerOptions(task.TaskName).wrap(func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task) {
+		authCompletionHandler := NewAuthCompletionHandler(complete.Task)
+		return authHandler(context, authCompletionHandler, modules)
+	}))
+
+	return handler(&task.Request, NewKinveyCompletionHandler(task), modules)
 }
 
 func (fa *auth) resolve(taskName string) func(context *Request, complete AuthCompletionHandler, modules Modules) (*Task, *Task) {
+	fa.mu.RLock()
+	defer fa.mu.RUnlock()
+
 	if i, ok := fa.authFunctions[taskName]; ok {
 		return i
 	}
 	return AuthNotImplementedHandler()
 }
 
+func (fa *auth) handlerOptions(taskName string) handlerOptions {
+	fa.mu.RLock()
+	defer fa.mu.RUnlock()
+
+	return fa.options[taskName]
+}
+
+func (fa *auth) setOptions(taskName string, apply func(o *handlerOptions)) {
+	fa.mu.Lock()
+	o := fa.options[taskName]
+	apply(&o)
+	fa.options[taskName] = o
+	fa.mu.Unlock()
+}
+
+// SetRateLimit limits how often each caller may run taskName.
+func (fa *auth) SetRateLimit(taskName string, limit RateLimit) {
+	fa.setOptions(taskName, func(o *handlerOptions) {
+		o.rate = newRateLimiter(limit)
+	})
+}
+
+// Describe sets the discovery metadata for taskName. Only the fields set in
+// metadata are changed.
+func (fa *auth) Describe(taskName string, metadata HandlerMetadata) {
+	fa.setOptions(taskName, func(o *handlerOptions) {
+		o.metadata = o.metadata.merge(metadata)
+	})
+}
+
 // Register ...
 func (fa *auth) Register(taskName string, functionToExecute func(context *Request, complete AuthCompletionHandler, modules Modules) (*Task, *Task)) {
+	fa.mu.Lock()
 	fa.authFunctions[taskName] = functionToExecute
+	fa.mu.Unlock()
 }
 
-func newAuth() Auth {
+func newAuth(middleware *middlewareStack) Auth {
 	ff := &auth{
 		authFunctions: make(map[string]func(req *Request, complete AuthCompletionHandler, modules Modules) (*Task, *Task)),
+		options:       make(map[string]handlerOptions),
+		middleware:    middleware,
 	}
 	return ff
 }
@@ -4,6 +4,37 @@ type authCompletionResponse struct {
 	Token string `json:"token"`
 }
 
+// authError is the OAuth 2.0 style error body auth tasks reply with.
+type authError struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+	Debug            string `json:"debug,omitempty"`
+}
+
+func setAuthError(task *Task, status int, code string, description string, debug string) {
+	body, _ := json.Marshal(authError{
+		Error:            code,
+		ErrorDescription: description,
+		Debug:            debug,
+	})
+
+	task.Response.Status = status
+	task.Response.Body = body
+}
+
+// authErrorCode maps a response status to the auth error code for it.
+func authErrorCode(status int) string {
+	switch {
+	case status == 400:
+		return "invalid_request"
+	case status == 401 || status == 403:
+		return "access_denied"
+	case status == 429 || status == 503:
+		return "temporarily_unavailable"
+	}
+	return "server_error"
+}
+
 // AuthCompletionHandler ...
 type AuthCompletionHandler struct {
 	task *Task
@@ -45,17 +76,17 @@ func (a *AuthCompletionHandler) OK() *AuthCompletionHandler {
 
 // ServerError ...
 func (a *AuthCompletionHandler) ServerError() {
-
+	setAuthError(a.task, 500, "server_error", "The Flex Service encountered an error while authenticating the user", "")
 }
 
 // AccessDenied ...
 func (a *AuthCompletionHandler) AccessDenied() {
-
+	setAuthError(a.task, 401, "access_denied", "The user could not be authenticated", "")
 }
 
 // TemporarilyUnavailable ...
 func (a *AuthCompletionHandler) TemporarilyUnavailable() {
-
+	setAuthError(a.task, 503, "temporarily_unavailable", "The Flex Service is temporarily unable to authenticate the user", "")
 }
 
 // NotImplemented ...
@@ -0,0 +1,471 @@
+package flex
+
+import (
+	"bytes"
+	gocontext "context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultRequestTimeout = 30 * time.Second
+)
+
+// TransportOptions configures the HTTP transport shared by every module of a
+// service, so connections to the Kinvey backend are pooled across tasks.
+// Fields left at zero keep their defaults.
+type TransportOptions struct {
+	// MaxIdleConns defaults to 100.
+	MaxIdleConns int
+	// MaxIdleConnsPerHost defaults to 32.
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost limits connections per host, including those in use. 0 means no limit.
+	MaxConnsPerHost int
+	// IdleConnTimeout defaults to 90 seconds.
+	IdleConnTimeout time.Duration
+	// DialTimeout bounds establishing a connection. Defaults to 10 seconds.
+	DialTimeout time.Duration
+	// KeepAlive is the interval between TCP keep-alive probes. Defaults to 30
+	// seconds.
+	KeepAlive time.Duration
+	// DisableHTTP2 stops the transport from attempting HTTP/2.
+	DisableHTTP2 bool
+	// Proxy selects a proxy for each request. Defaults to http.ProxyFromEnvironment.
+	Proxy func(*http.Request) (*url.URL, error)
+	// WrapTransport, when set, wraps the shared transport, e.g. to add tracing
+	// or to substitute a custom RoundTripper entirely.
+	WrapTransport func(http.RoundTripper) http.RoundTripper
+}
+
+func defaultTransportOptions() TransportOptions {
+	return TransportOptions{
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 32,
+		IdleConnTimeout:     90 * time.Second,
+		DialTimeout:         10 * time.Second,
+		KeepAlive:           30 * time.Second,
+		Proxy:               http.ProxyFromEnvironment,
+	}
+}
+
+// withDefaults fills the fields left at zero from the defaults.
+func (o TransportOptions) withDefaults() TransportOptions {
+	defaults := defaultTransportOptions()
+
+	if o.MaxIdleConns == 0 {
+		o.MaxIdleConns = defaults.MaxIdleConns
+	}
+	if o.MaxIdleConnsPerHost == 0 {
+		o.MaxIdleConnsPerHost = defaults.MaxIdleConnsPerHost
+	}
+	if o.IdleConnTimeout == 0 {
+		o.IdleConnTimeout = defaults.IdleConnTimeout
+	}
+	if o.DialTimeout == 0 {
+		o.DialTimeout = defaults.DialTimeout
+	}
+	if o.KeepAlive == 0 {
+		o.KeepAlive = defaults.KeepAlive
+	}
+	if o.Proxy == nil {
+		o.Proxy = defaults.Proxy
+	}
+
+	return o
+}
+
+func (o TransportOptions) roundTripper() http.RoundTripper {
+	dialer := &net.Dialer{
+		Timeout:   o.DialTimeout,
+		KeepAlive: o.KeepAlive,
+	}
+
+	var rt http.RoundTripper = &http.Transport{
+		Proxy:                 o.Proxy,
+		DialContext:           dialer.DialContext,
+		MaxIdleConns:          o.MaxIdleConns,
+		MaxIdleConnsPerHost:   o.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       o.MaxConnsPerHost,
+		IdleConnTimeout:       o.IdleConnTimeout,
+		ForceAttemptHTTP2:     !o.DisableHTTP2,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+
+	if o.WrapTransport != nil {
+		rt = o.WrapTransport(rt)
+	}
+
+	return rt
+}
+
+// RetryPolicy controls how requests to the Kinvey backend are retried.
+// Only idempotent requests are retried, after connection errors, 429s and
+// 5xx responses.
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt. 0 disables retries.
+	MaxRetries int
+	// BaseDelay is the backoff before the first retry. It doubles on every
+	// retry, with full jitter, up to MaxDelay.
+	BaseDelay time.Duration
+	// MaxDelay caps both the computed backoff and any Retry-After header.
+	// 0 uses the default of 5 seconds.
+	MaxDelay time.Duration
+}
+
+// CircuitBreakerPolicy controls the per-host circuit breaker. Once a host has
+// failed FailureThreshold times in a row, requests to it fail immediately
+// until OpenTimeout has passed, after which a single trial request is let
+// through.
+type CircuitBreakerPolicy struct {
+	// FailureThreshold is the number of consecutive failures that opens the
+	// circuit. 0 disables the breaker.
+	FailureThreshold int
+	OpenTimeout      time.Duration
+}
+
+// ErrCircuitOpen is returned for requests to a host whose circuit is open.
+var ErrCircuitOpen = errors.New("Circuit open: the Kinvey backend is unavailable")
+
+// ErrRequestTimeout is returned for requests to the Kinvey backend that took
+// longer than the request timeout.
+var ErrRequestTimeout = errors.New("Request to the Kinvey backend timed out")
+
+func defaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries: 2,
+		BaseDelay:  100 * time.Millisecond,
+		MaxDelay:   5 * time.Second,
+	}
+}
+
+func defaultCircuitBreakerPolicy() CircuitBreakerPolicy {
+	return CircuitBreakerPolicy{
+		FailureThreshold: 5,
+		OpenTimeout:      30 * time.Second,
+	}
+}
+
+// baasClient makes requests to the Kinvey backend on behalf of every module.
+// It is shared by all tasks of a service so pooled connections and circuit
+// breaker state survive between tasks.
+type baasClient struct {
+	client   *http.Client
+	timeout  time.Duration
+	retry    RetryPolicy
+	breakers *circuitBreakers
+}
+
+func newBaaSClient(options *Options) *baasClient {
+	return &baasClient{
+		client: &http.Client{
+			Transport: options.transport.roundTripper(),
+		},
+		timeout: options.requestTimeout,
+		retry:   options.retryPolicy,
+		breakers: &circuitBreakers{
+			policy:   options.circuitBreakerPolicy,
+			breakers: make(map[string]*circuitBreaker),
+		},
+	}
+}
+
+// do sends a request whose response is read in full. The request timeout
+// covers each attempt until its response body is closed.
+func (c *baasClient) do(req *http.Request) (*http.Response, error) {
+	return c.send(req, false)
+}
+
+// stream sends a request whose response body is read for as long as the
+// caller needs, such as a FindEach over a large collection. The request
+// timeout only covers each attempt until its response headers arrive; the
+// request's context bounds the rest.
+func (c *baasClient) stream(req *http.Request) (*http.Response, error) {
+	return c.send(req, true)
+}
+
+func (c *baasClient) send(req *http.Request, stream bool) (*http.Response, error) {
+	attempts := 1
+	if isIdempotent(req.Method) {
+		attempts += c.retry.MaxRetries
+	}
+
+	if attempts > 1 {
+		err := bufferBody(req)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	breaker := c.breakers.get(req.URL.Host)
+	if !breaker.allow() {
+		return nil, ErrCircuitOpen
+	}
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				breaker.record(false)
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		resp, err := c.attempt(req, stream)
+
+		// the caller giving up says nothing about the backend's health
+		if req.Context().Err() != nil {
+			breaker.abandon()
+			if err == nil {
+				return resp, nil
+			}
+			return nil, req.Context().Err()
+		}
+
+		breaker.record(err == nil && resp.StatusCode < 500)
+
+		retryable := err != nil || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		if !retryable || attempt+1 >= attempts {
+			return resp, err
+		}
+
+		delay := c.retry.backoff(attempt, resp)
+
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+
+		if !breaker.allow() {
+			return nil, ErrCircuitOpen
+		}
+	}
+}
+
+// attempt sends the request once, cancelling it once the request timeout
+// passes. The timer stops when the response body is closed, or when the
+// response headers arrive for a stream.
+func (c *baasClient) attempt(req *http.Request, stream bool) (*http.Response, error) {
+	if c.timeout <= 0 {
+		return c.client.Do(req)
+	}
+
+	ctx, cancel := gocontext.WithCancel(req.Context())
+	var timedOut int32
+	timer := time.AfterFunc(c.timeout, func() {
+		atomic.StoreInt32(&timedOut, 1)
+		cancel()
+	})
+
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		timer.Stop()
+		cancel()
+		if atomic.LoadInt32(&timedOut) == 1 {
+			return nil, ErrRequestTimeout
+		}
+		return nil, err
+	}
+
+	if stream {
+		timer.Stop()
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: func() {
+		timer.Stop()
+		cancel()
+	}}
+
+	return resp, nil
+}
+
+// cancelOnClose releases a request's context once its body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel func()
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "PUT", "DELETE":
+		return true
+	}
+	return false
+}
+
+// bufferBody reads the request body into memory so it can be replayed.
+func bufferBody(req *http.Request) error {
+	if req.Body == nil || req.GetBody != nil {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.Body, _ = req.GetBody()
+
+	return nil
+}
+
+func (p RetryPolicy) backoff(attempt int, resp *http.Response) time.Duration {
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = defaultRetryPolicy().MaxDelay
+	}
+
+	ceiling := p.BaseDelay << uint(attempt)
+	if ceiling > p.MaxDelay || ceiling <= 0 {
+		ceiling = p.MaxDelay
+	}
+
+	delay := time.Duration(0)
+	if ceiling > 0 {
+		delay = time.Duration(rand.Int63n(int64(ceiling) + 1))
+	}
+
+	if resp != nil {
+		if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok && retryAfter > delay {
+			delay = retryAfter
+		}
+	}
+
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	return delay
+}
+
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t), true
+	}
+
+	return 0, false
+}
+
+type circuitBreakers struct {
+	mu       sync.Mutex
+	policy   CircuitBreakerPolicy
+	breakers map[string]*circuitBreaker
+}
+
+func (cb *circuitBreakers) get(host string) *circuitBreaker {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	b, ok := cb.breakers[host]
+	if !ok {
+		b = &circuitBreaker{
+			policy: cb.policy,
+		}
+		cb.breakers[host] = b
+	}
+	return b
+}
+
+const (
+	circuitClosed = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+type circuitBreaker struct {
+	mu       sync.Mutex
+	policy   CircuitBreakerPolicy
+	state    int
+	failures int
+	openedAt time.Time
+}
+
+func (b *circuitBreaker) allow() bool {
+	if b.policy.FailureThreshold <= 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		if time.Since(b.openedAt) < b.policy.OpenTimeout {
+			return false
+		}
+		b.state = circuitHalfOpen
+		return true
+	case circuitHalfOpen:
+		// only the trial request goes through until it reports back
+		return false
+	}
+	return true
+}
+
+func (b *circuitBreaker) record(success bool) {
+	if b.policy.FailureThreshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if success {
+		b.failures = 0
+		b.state = circuitClosed
+		return
+	}
+
+	b.failures++
+	if b.state == circuitHalfOpen || b.failures >= b.policy.FailureThreshold {
+		b.state = circuitOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// abandon is called instead of record when the caller cancelled a request. A
+// trial request that never reported back lets the next request try again.
+func (b *circuitBreaker) abandon() {
+	if b.policy.FailureThreshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == circuitHalfOpen {
+		b.state = circuitOpen
+	}
+}
@@ -0,0 +1,177 @@
+package flex
+
+import (
+	gocontext "context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testBaaSClient(retry RetryPolicy, breaker CircuitBreakerPolicy) *baasClient {
+	return newBaaSClient(NewOptions("", 10001, "").SetRetryPolicy(retry).SetCircuitBreaker(breaker))
+}
+
+func TestBaaSClientRetriesIdempotentRequests(t *testing.T) {
+	var calls int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	c := testBaaSClient(RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}, CircuitBreakerPolicy{})
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	resp, err := c.do(req)
+	if err != nil || resp.StatusCode != 200 || atomic.LoadInt64(&calls) != 3 {
+		t.Fatalf("expected success on the third attempt, got %v after %d calls", err, calls)
+	}
+
+	atomic.StoreInt64(&calls, 0)
+	req, _ = http.NewRequest("POST", server.URL, strings.NewReader(`{}`))
+	resp, err = c.do(req)
+	if err != nil || resp.StatusCode != 503 || atomic.LoadInt64(&calls) != 1 {
+		t.Fatalf("expected a POST not to be retried, got %v after %d calls", err, calls)
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt := 0; attempt < 10; attempt++ {
+		if delay := p.backoff(attempt, nil); delay < 0 || delay > time.Second {
+			t.Fatalf("attempt %d: delay %s outside [0, MaxDelay]", attempt, delay)
+		}
+	}
+
+	retryAfter := &http.Response{Header: http.Header{"Retry-After": []string{"3"}}}
+	if delay := p.backoff(0, retryAfter); delay != time.Second {
+		t.Fatalf("expected Retry-After to be capped at MaxDelay, got %s", delay)
+	}
+
+	// a policy without MaxDelay still backs off
+	p = RetryPolicy{BaseDelay: 100 * time.Millisecond}
+	if delay := p.backoff(0, retryAfter); delay != 3*time.Second {
+		t.Fatalf("expected Retry-After to be honored with the default MaxDelay, got %s", delay)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	b := &circuitBreaker{policy: CircuitBreakerPolicy{FailureThreshold: 2, OpenTimeout: 10 * time.Millisecond}}
+
+	b.record(false)
+	if !b.allow() {
+		t.Fatal("opened before reaching the threshold")
+	}
+	b.record(false)
+	if b.allow() {
+		t.Fatal("did not open at the threshold")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if !b.allow() {
+		t.Fatal("did not let a trial request through after OpenTimeout")
+	}
+	if b.allow() {
+		t.Fatal("let a second request through while half-open")
+	}
+
+	b.record(false)
+	if b.allow() {
+		t.Fatal("a failed trial did not reopen the circuit")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	b.allow()
+	b.abandon()
+	if !b.allow() {
+		t.Fatal("an abandoned trial blocked the next one")
+	}
+
+	b.record(true)
+	if !b.allow() || !b.allow() {
+		t.Fatal("a successful trial did not close the circuit")
+	}
+}
+
+func TestBaaSClientIgnoresCancelledRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	c := testBaaSClient(RetryPolicy{}, CircuitBreakerPolicy{FailureThreshold: 1, OpenTimeout: time.Minute})
+
+	for i := 0; i < 3; i++ {
+		ctx, cancel := gocontext.WithTimeout(gocontext.Background(), 5*time.Millisecond)
+		req, _ := http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+		_, err := c.do(req)
+		cancel()
+
+		if err == ErrCircuitOpen {
+			t.Fatal("a cancelled request opened the circuit")
+		}
+	}
+}
+
+func TestSetTransportKeepsDefaults(t *testing.T) {
+	options := NewOptions("", 10001, "").SetTransport(TransportOptions{MaxConnsPerHost: 8})
+
+	if options.transport.MaxIdleConnsPerHost != 32 || options.transport.DialTimeout != 10*time.Second || options.transport.Proxy == nil {
+		t.Fatalf("a partial TransportOptions lost its defaults: %+v", options.transport)
+	}
+	if options.transport.MaxConnsPerHost != 8 {
+		t.Fatalf("expected MaxConnsPerHost to be kept, got %d", options.transport.MaxConnsPerHost)
+	}
+}
+
+func TestBaaSClientTimesOutRequestsButNotStreams(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(100 * time.Millisecond)
+		}
+
+		w.Write([]byte(`[`))
+		w.(http.Flusher).Flush()
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte(`]`))
+	}))
+	defer server.Close()
+
+	c := newBaaSClient(NewOptions("", 10001, "").SetRequestTimeout(50 * time.Millisecond).SetRetryPolicy(RetryPolicy{}))
+
+	req, _ := http.NewRequest("GET", server.URL+"/slow", nil)
+	if _, err := c.stream(req); err != ErrRequestTimeout {
+		t.Fatalf("expected a slow response to time out, got %v", err)
+	}
+
+	// a stream may take longer than the timeout once it has started
+	req, _ = http.NewRequest("GET", server.URL, nil)
+	resp, err := c.stream(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || string(body) != "[]" {
+		t.Fatalf("expected the whole stream, got %q, %v", body, err)
+	}
+
+	// a response read in full may not
+	req, _ = http.NewRequest("GET", server.URL, nil)
+	resp, err = c.do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err == nil {
+		t.Fatal("expected reading a slow body to time out")
+	}
+}
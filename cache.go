@@ -0,0 +1,273 @@
+package flex
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache is a store for cached data responses. Keys for a service object all
+// start with the same prefix, which Invalidate is given when the object is
+// written to.
+type Cache interface {
+	Get(key string) (CachedResponse, bool)
+	Set(key string, response CachedResponse, ttl time.Duration)
+	Invalidate(prefix string)
+}
+
+// CachedResponse ...
+type CachedResponse struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers"`
+	Body    []byte            `json:"body"`
+}
+
+// CacheOptions configures caching for a ServiceObject.
+type CacheOptions struct {
+	// TTL is how long responses stay cached. 0 means entries never expire and
+	// are only dropped by writes or eviction.
+	TTL time.Duration
+	// Operations lists the data operations to cache. Defaults to every read
+	// operation.
+	Operations []string
+	// Backend defaults to an in-memory LRU cache of 1000 responses.
+	Backend Cache
+}
+
+var (
+	cacheableOps = []string{"onGetAll", "onGetByID", "onGetByQuery", "onGetCount", "onGetCountByQuery"}
+	writeOps     = []string{"onInsert", "onInsertMany", "onUpdate", "onPatch", "onDeleteAll", "onDeleteByID", "onDeleteByQuery"}
+)
+
+type responseCache struct {
+	backend    Cache
+	ttl        time.Duration
+	operations map[string]bool
+
+	// generation counts invalidations, so a read that started before a
+	// write does not cache its stale response after the write invalidated.
+	mu         sync.Mutex
+	generation uint64
+}
+
+func newResponseCache(options CacheOptions) *responseCache {
+	operations := options.Operations
+	if len(operations) == 0 {
+		operations = cacheableOps
+	}
+
+	backend := options.Backend
+	if backend == nil {
+		backend = NewLRUCache(1000)
+	}
+
+	c := &responseCache{
+		backend:    backend,
+		ttl:        options.TTL,
+		operations: make(map[string]bool),
+	}
+	for _, op := range operations {
+		c.operations[op] = true
+	}
+	return c
+}
+
+func cachePrefix(serviceObjectName string) string {
+	return serviceObjectName + "|"
+}
+
+// cacheKey identifies a read by what it asks for and who is asking, so
+// responses are never shared between users.
+func cacheKey(serviceObjectName string, dataOp string, context *Request, modules Modules) string {
+	requestContext := modules.RequestContext()
+
+	caller := requestContext.GetSecurityContext()
+	if requestContext.IsUser() {
+		if userID := requestContext.GetAuthenticatedUserID(); userID != "" {
+			caller += ":" + userID
+		} else {
+			sum := sha256.Sum256([]byte(context.Headers["authorization"]))
+			caller += ":" + hex.EncodeToString(sum[:])
+		}
+	}
+
+	return strings.Join([]string{
+		serviceObjectName,
+		dataOp,
+		context.EntityID,
+		context.Query.Encode(),
+		caller,
+	}, "|")
+}
+
+// isSuccess treats an unset status as success, since receivers send it as a
+// 200.
+func isSuccess(status int) bool {
+	return status == 0 || (status >= 200 && status < 300)
+}
+
+func (c *responseCache) currentGeneration() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// set caches response unless the cache was invalidated since generation.
+func (c *responseCache) set(key string, generation uint64, response CachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.generation == generation {
+		c.backend.Set(key, response, c.ttl)
+	}
+}
+
+func (c *responseCache) invalidate(serviceObjectName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.backend.Invalidate(cachePrefix(serviceObjectName))
+}
+
+// wrap serves dataOp from the cache when it is cached, and invalidates the
+// service object's entries after a successful write.
+func (c *responseCache) wrap(serviceObjectName string, dataOp string, next HandlerFunc) HandlerFunc {
+	if c.operations[dataOp] {
+		return func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task) {
+			key := cacheKey(serviceObjectName, dataOp, context, modules)
+
+			if cached, ok := c.backend.Get(key); ok {
+				complete.Task.Response.Status = cached.Status
+				complete.Task.Response.Headers = copyHeaders(cached.Headers)
+				complete.Task.Response.Body = cached.Body
+				return complete.Done()
+			}
+
+			generation := c.currentGeneration()
+			taskErr, result := next(context, complete, modules)
+			if taskErr == nil && result != nil && isSuccess(result.Response.Status) {
+				c.set(key, generation, CachedResponse{
+					Status:  result.Response.Status,
+					Headers: copyHeaders(result.Response.Headers),
+					Body:    result.Response.Body,
+				})
+			}
+			return taskErr, result
+		}
+	}
+
+	for _, op := range writeOps {
+		if op == dataOp {
+			return func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task) {
+				taskErr, result := next(context, complete, modules)
+				if taskErr == nil && result != nil && isSuccess(result.Response.Status) {
+					c.invalidate(serviceObjectName)
+				}
+				return taskErr, result
+			}
+		}
+	}
+
+	return next
+}
+
+func copyHeaders(headers map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+	c := make(map[string]string, len(headers))
+	for k, v := range headers {
+		c[k] = v
+	}
+	return c
+}
+
+// NewLRUCache returns an in-memory Cache holding at most capacity responses,
+// evicting the least recently used first.
+func NewLRUCache(capacity int) Cache {
+	return &lruCache{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+type lruCache struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List
+}
+
+type lruEntry struct {
+	key      string
+	response CachedResponse
+	expires  time.Time
+}
+
+func (c *lruCache) Get(key string) (CachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return CachedResponse{}, false
+	}
+
+	entry := e.Value.(*lruEntry)
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		c.remove(e)
+		return CachedResponse{}, false
+	}
+
+	c.order.MoveToFront(e)
+	return entry.response, true
+}
+
+func (c *lruCache) Set(key string, response CachedResponse, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var expires time.Time
+	if ttl > 0 {
+		expires = time.Now().Add(ttl)
+	}
+
+	if e, ok := c.entries[key]; ok {
+		entry := e.Value.(*lruEntry)
+		entry.response = response
+		entry.expires = expires
+		c.order.MoveToFront(e)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&lruEntry{
+		key:      key,
+		response: response,
+		expires:  expires,
+	})
+
+	for c.capacity > 0 && c.order.Len() > c.capacity {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *lruCache) Invalidate(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, e := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			c.remove(e)
+		}
+	}
+}
+
+func (c *lruCache) remove(e *list.Element) {
+	c.order.Remove(e)
+	delete(c.entries, e.Value.(*lruEntry).key)
+}
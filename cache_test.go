@@ -0,0 +1,159 @@
+package flex
+
+import (
+	"testing"
+	"time"
+)
+
+func TestServiceObjectCache(t *testing.T) {
+	fd := newData(newMiddlewareStack())
+	widgets := fd.NewServiceObject("widgets")
+	widgets.EnableCache(CacheOptions{TTL: time.Minute})
+
+	calls := 0
+	widgets.OnGetByID(func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task) {
+		calls++
+		return complete.SetBody([]byte(`{"_id":"` + context.EntityID + `"}`)).OK().Done()
+	})
+	widgets.OnInsert(func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task) {
+		return complete.SetBody(context.Body).Created().Done()
+	})
+
+	baas := newBaaSClient(NewOptions("", 10001, ""))
+	run := func(method string, userID string) *Task {
+		task := &Task{
+			TaskType:    "data",
+			Method:      method,
+			AppMetadata: kinveyAppMetadata{ID: "kid_abc123"},
+			Request: Request{
+				Method:            method,
+				ServiceObjectName: "widgets",
+				EntityID:          "123",
+				UserID:            userID,
+				Headers:           map[string]string{"authorization": "Kinvey " + userID + "-session"},
+			},
+		}
+		if method == "POST" {
+			task.Request.EntityID = ""
+			task.Request.Body = []byte(`{"name":"widget"}`)
+		}
+		_, result := fd.process(task, generateModules(task, baas))
+		return result
+	}
+
+	run("GET", "alice")
+	if result := run("GET", "alice"); calls != 1 || string(result.Response.Body) != `{"_id":"123"}` || result.Response.Status != 200 {
+		t.Fatalf("second read was not served from the cache: calls %d, %d %s", calls, result.Response.Status, result.Response.Body)
+	}
+
+	run("GET", "bob")
+	if calls != 2 {
+		t.Fatalf("a different user was served another user's response: calls %d", calls)
+	}
+
+	run("POST", "alice")
+	run("GET", "alice")
+	if calls != 3 {
+		t.Fatalf("insert did not invalidate the cache: calls %d", calls)
+	}
+}
+
+func TestServiceObjectCacheInvalidatesOnUnsetStatus(t *testing.T) {
+	fd := newData(newMiddlewareStack())
+	widgets := fd.NewServiceObject("widgets")
+	widgets.EnableCache(CacheOptions{})
+
+	calls := 0
+	widgets.OnGetAll(func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task) {
+		calls++
+		return complete.SetBody([]byte(`[]`)).Done()
+	})
+	// like the README examples, the write never sets a status
+	widgets.OnInsert(func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task) {
+		return complete.SetBody(context.Body).Done()
+	})
+
+	baas := newBaaSClient(NewOptions("", 10001, ""))
+	run := func(method string) {
+		task := &Task{Method: method, Request: Request{ServiceObjectName: "widgets"}}
+		if method == "POST" {
+			task.Request.Body = []byte(`{"name":"widget"}`)
+		}
+		fd.process(task, generateModules(task, baas))
+	}
+
+	run("GET")
+	run("GET")
+	if calls != 1 {
+		t.Fatalf("a read with no status set was not cached: calls %d", calls)
+	}
+
+	run("POST")
+	run("GET")
+	if calls != 2 {
+		t.Fatalf("a write with no status set did not invalidate the cache: calls %d", calls)
+	}
+}
+
+func TestResponseCacheDropsReadsOverlappingWrites(t *testing.T) {
+	c := newResponseCache(CacheOptions{})
+
+	reading := make(chan struct{})
+	written := make(chan struct{})
+	read := c.wrap("widgets", "onGetAll", func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task) {
+		close(reading)
+		<-written
+		return complete.SetBody([]byte(`["stale"]`)).OK().Done()
+	})
+	write := c.wrap("widgets", "onInsert", func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task) {
+		return complete.Created().Done()
+	})
+
+	baas := newBaaSClient(NewOptions("", 10001, ""))
+	task := &Task{Request: Request{ServiceObjectName: "widgets"}}
+	modules := generateModules(task, baas)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		read(&task.Request, NewKinveyCompletionHandler(task), modules)
+	}()
+
+	<-reading
+	writeTask := &Task{Request: Request{ServiceObjectName: "widgets"}}
+	write(&writeTask.Request, NewKinveyCompletionHandler(writeTask), modules)
+	close(written)
+	<-done
+
+	if _, ok := c.backend.Get(cacheKey("widgets", "onGetAll", &task.Request, modules)); ok {
+		t.Fatal("a read that started before a write cached its stale response")
+	}
+}
+
+func TestLRUCache(t *testing.T) {
+	c := NewLRUCache(2)
+
+	c.Set("a", CachedResponse{Status: 200}, 0)
+	c.Set("b", CachedResponse{Status: 200}, 0)
+	c.Get("a")
+	c.Set("c", CachedResponse{Status: 200}, 0)
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("least recently used entry was not evicted")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Error("recently used entry was evicted")
+	}
+
+	c.Set("d", CachedResponse{Status: 200}, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if _, ok := c.Get("d"); ok {
+		t.Error("expired entry was returned")
+	}
+
+	c.Set("x|1", CachedResponse{}, 0)
+	c.Invalidate("x|")
+	if _, ok := c.Get("x|1"); ok {
+		t.Error("invalidated entry was returned")
+	}
+}
@@ -0,0 +1,374 @@
+// Command flexrun replays a JSONL task recording against a running Flex
+// service and reports responses that differ from the recorded ones.
+//
+// Usage:
+//
+//	flexrun -target tcp://localhost:7000 -auth-key <shared secret> recording.jsonl
+//	flexrun -target http://localhost:10001 -ignore body._kmd.lmt recording.jsonl
+//
+// To replay in-process instead, run the service itself with
+// SDK_RECEIVER=replay and SDK_REPLAY_FILE=recording.jsonl.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	flex "github.com/timw255/flex-go"
+)
+
+var json = jsoniter.ConfigCompatibleWithStandardLibrary
+
+type replayer interface {
+	replay(task *flex.Task) (*flex.RecordedResponse, error)
+	Close() error
+}
+
+func main() {
+	target := flag.String("target", "tcp://localhost:7000", "service to replay against, as tcp://host:port or http://host:port")
+	authKey := flag.String("auth-key", "", "shared secret to send in place of the redacted auth key")
+	ignore := flag.String("ignore", "", "comma-separated response paths to leave out of the comparison, e.g. headers,body._kmd")
+	timeout := flag.Duration("timeout", 30*time.Second, "time to wait for each response")
+	verbose := flag.Bool("v", false, "also list tasks whose responses matched")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: flexrun [flags] recording.jsonl...")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	var ignored []string
+	if *ignore != "" {
+		ignored = strings.Split(*ignore, ",")
+	}
+
+	r, err := newReplayer(*target, *timeout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer r.Close()
+
+	total, failed, failedAsRecorded := 0, 0, 0
+	for _, path := range flag.Args() {
+		recordings, err := readRecordings(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		for i, recording := range recordings {
+			result := replay(r, recording, *authKey, ignored)
+			result.Index = i
+
+			total++
+			if result.Failed() {
+				failed++
+				fmt.Println(path + " " + result.String())
+			} else if result.FailedAsRecorded() {
+				failedAsRecorded++
+				fmt.Println(path + " " + result.String())
+			} else if *verbose {
+				fmt.Println(path + " " + result.String())
+			}
+		}
+	}
+
+	fmt.Printf("Replayed %d tasks, %d mismatched, %d failed as recorded\n", total, failed, failedAsRecorded)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func readRecordings(path string) ([]flex.Recording, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return flex.ReadRecordings(f)
+}
+
+func replay(r replayer, recording flex.Recording, authKey string, ignore []string) flex.ReplayResult {
+	result := flex.ReplayResult{Expected: recording.Response}
+
+	task, err := flex.DecodeTask(recording.Task)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.TaskID = task.TaskID
+	task.AuthKey = authKey
+
+	// the HTTP receiver does not send response headers for data and auth tasks
+	if _, ok := r.(*httpReplayer); ok && (task.TaskType == "data" || task.TaskType == "auth") {
+		ignore = append(ignore[:len(ignore):len(ignore)], "headers")
+	}
+
+	result.Actual, result.Err = r.replay(task)
+	if result.Err == nil {
+		result.Differences = flex.DiffResponses(result.Expected, result.Actual, ignore...)
+	}
+
+	return result
+}
+
+func newReplayer(target string, timeout time.Duration) (replayer, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "tcp":
+		r := &tcpReplayer{addr: u.Host, timeout: timeout}
+		if err := r.dial(); err != nil {
+			return nil, err
+		}
+		return r, nil
+	case "http", "https":
+		return &httpReplayer{baseURL: strings.TrimSuffix(target, "/"), client: &http.Client{Timeout: timeout}}, nil
+	}
+
+	return nil, errors.New("Target must be a tcp:// or http:// address")
+}
+
+// tcpReplayer sends one task at a time over a single connection. After a
+// timeout or any other connection error it reconnects before the next task,
+// so a late reply to one task is never read as the reply to the next.
+type tcpReplayer struct {
+	addr    string
+	conn    net.Conn
+	replies *bufio.Reader
+	timeout time.Duration
+}
+
+func (r *tcpReplayer) dial() error {
+	conn, err := net.DialTimeout("tcp", r.addr, r.timeout)
+	if err != nil {
+		return err
+	}
+	r.conn = conn
+	r.replies = bufio.NewReader(conn)
+	return nil
+}
+
+func (r *tcpReplayer) replay(task *flex.Task) (*flex.RecordedResponse, error) {
+	data, err := flex.EncodeTask(task)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.conn == nil {
+		if err := r.dial(); err != nil {
+			return nil, err
+		}
+	}
+
+	reply, err := r.roundTrip(data)
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+
+	header := struct {
+		TaskID  string `json:"taskId"`
+		IsError bool   `json:"isError"`
+		Error   string `json:"error"`
+	}{}
+	if err := json.Unmarshal(reply, &header); err != nil {
+		r.Close()
+		return nil, err
+	}
+	if header.TaskID != "" && header.TaskID != task.TaskID {
+		r.Close()
+		return nil, fmt.Errorf("Received the reply to task %s instead", header.TaskID)
+	}
+	if header.IsError {
+		return nil, errors.New(header.Error)
+	}
+
+	result, err := flex.DecodeTask(reply)
+	if err != nil {
+		return nil, err
+	}
+
+	return flex.NewRecordedResponse(result), nil
+}
+
+func (r *tcpReplayer) roundTrip(data []byte) ([]byte, error) {
+	r.conn.SetDeadline(time.Now().Add(r.timeout))
+	if _, err := r.conn.Write(append(data, '\n')); err != nil {
+		return nil, err
+	}
+
+	return r.replies.ReadBytes('\n')
+}
+
+func (r *tcpReplayer) Close() error {
+	if r.conn == nil {
+		return nil
+	}
+
+	err := r.conn.Close()
+	r.conn = nil
+	return err
+}
+
+// httpReplayer turns each task back into the request the HTTP receiver
+// would have built it from.
+type httpReplayer struct {
+	baseURL string
+	client  *http.Client
+}
+
+type functionsRequest struct {
+	Body            jsoniter.RawMessage    `json:"body,omitempty"`
+	HookType        string                 `json:"hookType,omitempty"`
+	Method          string                 `json:"method,omitempty"`
+	Query           string                 `json:"query,omitempty"`
+	ObjectName      string                 `json:"objectName,omitempty"`
+	EntityID        string                 `json:"entityId,omitempty"`
+	TempObjectStore map[string]interface{} `json:"tempObjectStore,omitempty"`
+}
+
+func (r *httpReplayer) request(task *flex.Task) (*http.Request, error) {
+	var method, path string
+	var body []byte
+
+	switch task.TaskType {
+	case "data":
+		method = task.Method
+		path = "/" + url.PathEscape(task.Request.ServiceObjectName)
+		if task.Endpoint == "_count" {
+			path += "/_count"
+		} else if task.Request.EntityID != "" {
+			path += "/" + url.PathEscape(task.Request.EntityID)
+		}
+		if len(task.Request.Query) > 0 {
+			path += "?" + task.Request.Query.Encode()
+		}
+		body = task.Request.Body
+	case "functions", "auth":
+		method = http.MethodPost
+		if task.TaskType == "functions" {
+			path = "/_flexFunctions/" + url.PathEscape(task.TaskName)
+		} else {
+			path = "/_auth/" + url.PathEscape(task.TaskName)
+		}
+
+		fr := functionsRequest{
+			HookType:        task.HookType,
+			Method:          task.Request.Method,
+			Query:           task.Request.Query.Encode(),
+			ObjectName:      task.Request.ObjectName,
+			EntityID:        task.Request.EntityID,
+			TempObjectStore: task.Request.TempObjectStore,
+		}
+		if json.Valid(task.Request.Body) {
+			fr.Body = task.Request.Body
+		}
+
+		encoded, err := json.Marshal(fr)
+		if err != nil {
+			return nil, err
+		}
+		body = encoded
+	case "serviceDiscovery":
+		method = http.MethodPost
+		path = "/_command/discover"
+	default:
+		return nil, errors.New("Cannot replay " + task.TaskType + " tasks over HTTP")
+	}
+
+	req, err := http.NewRequest(method, r.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	appMetadata, _ := json.Marshal(task.AppMetadata)
+	originalHeaders, _ := json.Marshal(task.Request.Headers)
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Kinvey-App-Metadata", string(appMetadata))
+	req.Header.Set("X-Kinvey-Original-Request-Headers", string(originalHeaders))
+	req.Header.Set("X-Kinvey-Environment-Id", task.AppID)
+	req.Header.Set("X-Auth-Key", task.AuthKey)
+	req.Header.Set("X-Kinvey-Request-Id", task.RequestID)
+	req.Header.Set("X-Kinvey-Username", task.Request.Username)
+	req.Header.Set("X-Kinvey-User-Id", task.Request.UserID)
+
+	// after hooks see the response being returned
+	if task.Response.Status != 0 {
+		req.Header.Set("X-Kinvey-Response-Status", strconv.Itoa(task.Response.Status))
+	}
+	if len(task.Response.Headers) > 0 {
+		responseHeaders, _ := json.Marshal(task.Response.Headers)
+		req.Header.Set("X-Kinvey-Response-Headers", string(responseHeaders))
+	}
+	if len(task.Response.Body) > 0 {
+		req.Header.Set("X-Kinvey-Response-Body", string(task.Response.Body))
+	}
+
+	return req, nil
+}
+
+func (r *httpReplayer) replay(task *flex.Task) (*flex.RecordedResponse, error) {
+	req, err := r.request(task)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, 26214400))
+	if err != nil {
+		return nil, err
+	}
+
+	if res.Header.Get("X-Kinvey-Request-Continue") == "true" {
+		return nil, errors.New("Task failed")
+	}
+
+	if task.TaskType == "functions" {
+		fr := struct {
+			Response flex.RecordedResponse `json:"response"`
+		}{}
+		if err := json.Unmarshal(body, &fr); err != nil {
+			return nil, fmt.Errorf("Error parsing functions response: %v", err)
+		}
+		// the receiver sends a status the handler never set as a 200
+		if fr.Response.Status == 0 {
+			fr.Response.Status = http.StatusOK
+		}
+		return &fr.Response, nil
+	}
+
+	return &flex.RecordedResponse{
+		Status: res.StatusCode,
+		Body:   body,
+	}, nil
+}
+
+func (r *httpReplayer) Close() error {
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+
+	flex "github.com/timw255/flex-go"
+)
+
+// A reply that arrives after the timeout must not be taken as the reply to
+// the next task.
+func TestTCPReplayerReconnectsAfterTimeout(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				lines := bufio.NewReader(conn)
+				for {
+					line, err := lines.ReadBytes('\n')
+					if err != nil {
+						return
+					}
+					task, _ := flex.DecodeTask(line)
+					task.Response.Status = 200
+					if task.TaskID == "slow" {
+						time.Sleep(100 * time.Millisecond)
+						task.Response.Status = 500
+					}
+					reply, _ := flex.EncodeTask(task)
+					conn.Write(append(reply, '\n'))
+				}
+			}(conn)
+		}
+	}()
+
+	r, err := newReplayer("tcp://"+listener.Addr().String(), 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	if _, err := r.replay(&flex.Task{TaskID: "slow", TaskType: "data"}); err == nil {
+		t.Fatal("expected the slow task to time out")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	actual, err := r.replay(&flex.Task{TaskID: "next", TaskType: "data"})
+	if err != nil || actual == nil || actual.Status != 200 {
+		t.Fatalf("expected the next task to get its own reply, got %+v, %v", actual, err)
+	}
+}
+
+func TestReplayReportsTasksThatFailedAsRecorded(t *testing.T) {
+	r := &tcpReplayer{addr: "127.0.0.1:0", timeout: time.Millisecond}
+
+	result := replay(r, flex.Recording{Task: []byte(`{"taskId":"t1","taskType":"data"}`)}, "", nil)
+	if result.Err == nil || !result.FailedAsRecorded() || result.Failed() {
+		t.Fatalf("expected the task to fail as recorded, got %s", result)
+	}
+}
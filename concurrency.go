@@ -0,0 +1,88 @@
+package flex
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// ConcurrencyLimit bounds how many tasks run at once. Tasks over the limit
+// wait in a queue of up to MaxQueued tasks for at most QueueTimeout; tasks
+// that find the queue full or time out are shed with a retryable 503.
+type ConcurrencyLimit struct {
+	// MaxConcurrent is the number of tasks allowed to run at once. 0 means no limit.
+	MaxConcurrent int
+	// MaxQueued is the number of tasks allowed to wait for a slot. 0 queues
+	// up to MaxConcurrent tasks; a negative value sheds tasks over the limit
+	// without queueing them.
+	MaxQueued int
+	// QueueTimeout is how long a queued task waits for a slot. 0 means it
+	// waits until one is free.
+	QueueTimeout time.Duration
+}
+
+type concurrencyLimiter struct {
+	limit  ConcurrencyLimit
+	slots  chan struct{}
+	queued int64
+}
+
+// newConcurrencyLimiter returns nil when limit does not restrict anything.
+func newConcurrencyLimiter(limit ConcurrencyLimit) *concurrencyLimiter {
+	if limit.MaxConcurrent <= 0 {
+		return nil
+	}
+	if limit.MaxQueued == 0 {
+		limit.MaxQueued = limit.MaxConcurrent
+	}
+
+	return &concurrencyLimiter{
+		limit: limit,
+		slots: make(chan struct{}, limit.MaxConcurrent),
+	}
+}
+
+// acquire reserves a slot, queueing if allowed. Every successful acquire
+// must be followed by a release.
+func (l *concurrencyLimiter) acquire() bool {
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	if atomic.AddInt64(&l.queued, 1) > int64(l.limit.MaxQueued) {
+		atomic.AddInt64(&l.queued, -1)
+		return false
+	}
+	defer atomic.AddInt64(&l.queued, -1)
+
+	if l.limit.QueueTimeout <= 0 {
+		l.slots <- struct{}{}
+		return true
+	}
+
+	timer := time.NewTimer(l.limit.QueueTimeout)
+	defer timer.Stop()
+
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+func (l *concurrencyLimiter) release() {
+	<-l.slots
+}
+
+func limitConcurrency(limiter *concurrencyLimiter, next HandlerFunc) HandlerFunc {
+	return func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task) {
+		if !limiter.acquire() {
+			return complete.ServiceUnavailable("Too many concurrent requests for this handler").Done()
+		}
+		defer limiter.release()
+
+		return next(context, complete, modules)
+	}
+}
@@ -0,0 +1,99 @@
+package flex
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	limiter := newConcurrencyLimiter(ConcurrencyLimit{MaxConcurrent: 1, MaxQueued: 1, QueueTimeout: time.Second})
+
+	if !limiter.acquire() {
+		t.Fatal("first acquire was shed")
+	}
+
+	queued := make(chan bool)
+	go func() {
+		queued <- limiter.acquire()
+	}()
+
+	// wait for the second acquire to take the only queue slot
+	for atomic.LoadInt64(&limiter.queued) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if limiter.acquire() {
+		t.Fatal("acquire with a full queue was not shed")
+	}
+
+	limiter.release()
+	if !<-queued {
+		t.Fatal("queued acquire was shed after a slot was released")
+	}
+	limiter.release()
+
+	limiter.limit.QueueTimeout = 10 * time.Millisecond
+	if !limiter.acquire() {
+		t.Fatal("acquire after release was shed")
+	}
+	if limiter.acquire() {
+		t.Fatal("queued acquire did not time out")
+	}
+}
+
+func TestConcurrencyLimiterQueuesByDefault(t *testing.T) {
+	limiter := newConcurrencyLimiter(ConcurrencyLimit{MaxConcurrent: 2, QueueTimeout: 10 * time.Millisecond})
+	if limiter.limit.MaxQueued != 2 {
+		t.Fatalf("expected the queue to default to MaxConcurrent, got %d", limiter.limit.MaxQueued)
+	}
+
+	limiter = newConcurrencyLimiter(ConcurrencyLimit{MaxConcurrent: 1, MaxQueued: -1, QueueTimeout: time.Second})
+	limiter.acquire()
+	start := time.Now()
+	if limiter.acquire() || time.Since(start) >= time.Second {
+		t.Fatal("expected a negative MaxQueued to shed without queueing")
+	}
+}
+
+func TestProcessTaskSheds(t *testing.T) {
+	options := NewOptions("", 10001, "")
+	options.SetConcurrencyLimit("functions", ConcurrencyLimit{MaxConcurrent: 1, MaxQueued: -1})
+	s := newFlex(options)
+
+	started := make(chan bool)
+	finish := make(chan bool)
+	s.Functions.Register("slow", func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task) {
+		started <- true
+		<-finish
+		return complete.OK().Done()
+	})
+
+	go s.processTask(&Task{TaskType: "functions", TaskName: "slow"})
+	<-started
+
+	_, result := s.processTask(&Task{TaskType: "functions", TaskName: "slow"})
+	if result.Response.Status != 503 || !result.retryable {
+		t.Fatalf("got status %d retryable %v, want a retryable 503", result.Response.Status, result.retryable)
+	}
+
+	close(finish)
+}
+
+func TestPolicyRunsBeforeConcurrencyLimit(t *testing.T) {
+	limiter := newConcurrencyLimiter(ConcurrencyLimit{MaxConcurrent: 1, MaxQueued: -1})
+	handler := handlerOptions{policy: &Policy{Users: true}, limiter: limiter}.wrap(func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task) {
+		return complete.OK().Done()
+	})
+
+	// with every slot taken, an unauthenticated caller is still told so
+	// rather than shed, since it never needs a slot
+	limiter.acquire()
+	defer limiter.release()
+
+	task := &Task{AppID: "kid_abc123", Request: Request{Headers: map[string]string{}}}
+	_, result := handler(&task.Request, NewKinveyCompletionHandler(task), generateModules(task, newBaaSClient(NewOptions("", 10001, ""))))
+	if result.Response.Status != 401 {
+		t.Fatalf("got status %d, want 401", result.Response.Status)
+	}
+}
@@ -0,0 +1,221 @@
+package flex
+
+import (
+	gocontext "context"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+const defaultPageSize = 1000
+
+// PageOptions ...
+type PageOptions struct {
+	// PageSize is the number of entities requested per page. Defaults to 1000.
+	// The server may return fewer, so the cursor pages until it gets an
+	// empty page.
+	PageSize int
+	// ByID pages on _id ranges instead of skip/limit, which stays fast and
+	// consistent on large collections. Sort is ignored when set.
+	ByID   bool
+	Fields []string
+	Sort   string
+}
+
+// Cursor pages through the results of a query. Call Next until it returns
+// false, then check Err.
+type Cursor struct {
+	ctx        gocontext.Context
+	collection collection
+	query      string
+	options    PageOptions
+
+	page    []jsoniter.RawMessage
+	index   int
+	skip    int
+	lastID  string
+	current jsoniter.RawMessage
+	done    bool
+	err     error
+}
+
+type idOnly struct {
+	ID string `json:"_id"`
+}
+
+func (c collection) FindAll(ctx gocontext.Context, query string, options PageOptions) *Cursor {
+	if options.PageSize <= 0 {
+		options.PageSize = defaultPageSize
+	}
+
+	return &Cursor{
+		ctx:        ctx,
+		collection: c,
+		query:      query,
+		options:    options,
+	}
+}
+
+// Next advances to the next entity, fetching another page when needed. It
+// returns false when the results are exhausted, the context is cancelled or a
+// request fails.
+func (c *Cursor) Next() bool {
+	if c.err != nil {
+		return false
+	}
+
+	if err := c.ctx.Err(); err != nil {
+		c.err = err
+		return false
+	}
+
+	if c.index >= len(c.page) {
+		if c.done {
+			return false
+		}
+
+		err := c.fetch()
+		if err != nil {
+			c.err = err
+			return false
+		}
+
+		if len(c.page) == 0 {
+			return false
+		}
+	}
+
+	c.current = c.page[c.index]
+	c.index++
+
+	return true
+}
+
+// Entity returns the raw JSON of the current entity.
+func (c *Cursor) Entity() []byte {
+	return c.current
+}
+
+// Decode unmarshals the current entity into v.
+func (c *Cursor) Decode(v interface{}) error {
+	return json.Unmarshal(c.current, v)
+}
+
+// Err ...
+func (c *Cursor) Err() error {
+	return c.err
+}
+
+func (c *Cursor) fetch() error {
+	findOptions := FindOptions{
+		Fields: c.options.Fields,
+		Sort:   c.options.Sort,
+		Limit:  c.options.PageSize,
+	}
+
+	query := c.query
+
+	if c.options.ByID {
+		findOptions.Sort = `{"_id":1}`
+
+		if c.lastID != "" {
+			q, err := afterID(query, c.lastID)
+			if err != nil {
+				return err
+			}
+			query = q
+		}
+	} else {
+		findOptions.Skip = c.skip
+	}
+
+	kinveyResponse, err := c.collection.find(c.ctx, query, findOptions)
+	if err != nil {
+		return err
+	}
+
+	page := make([]jsoniter.RawMessage, 0)
+	err = json.Unmarshal(kinveyResponse, &page)
+	if err != nil {
+		return err
+	}
+
+	c.page = page
+	c.index = 0
+	c.skip += len(page)
+	// a short page is not the last one when the server caps page sizes below
+	// PageSize, so only an empty page ends the results
+	c.done = len(page) == 0
+
+	if c.options.ByID && len(page) > 0 {
+		last := idOnly{}
+		err = json.Unmarshal(page[len(page)-1], &last)
+		if err != nil {
+			return err
+		}
+		c.lastID = last.ID
+	}
+
+	return nil
+}
+
+// afterID narrows query to entities whose _id sorts after id.
+func afterID(query string, id string) (string, error) {
+	after := map[string]interface{}{
+		"_id": map[string]interface{}{"$gt": id},
+	}
+
+	if query == "" {
+		b, err := json.Marshal(after)
+		return string(b), err
+	}
+
+	var q interface{}
+	err := json.Unmarshal([]byte(query), &q)
+	if err != nil {
+		return "", err
+	}
+
+	b, err := json.Marshal(map[string]interface{}{
+		"$and": []interface{}{q, after},
+	})
+	return string(b), err
+}
+
+// TypedCursor is a Cursor that decodes each entity into T.
+type TypedCursor[T any] struct {
+	cursor  *Cursor
+	current T
+}
+
+// FindAll ...
+func (c TypedCollection[T]) FindAll(ctx gocontext.Context, query string, options PageOptions) *TypedCursor[T] {
+	return &TypedCursor[T]{
+		cursor: c.collection.FindAll(ctx, query, options),
+	}
+}
+
+// Next ...
+func (c *TypedCursor[T]) Next() bool {
+	if !c.cursor.Next() {
+		return false
+	}
+
+	var entity T
+	if err := c.cursor.Decode(&entity); err != nil {
+		c.cursor.err = err
+		return false
+	}
+	c.current = entity
+
+	return true
+}
+
+// Value returns the current entity.
+func (c *TypedCursor[T]) Value() T {
+	return c.current
+}
+
+// Err ...
+func (c *TypedCursor[T]) Err() error {
+	return c.cursor.Err()
+}
@@ -0,0 +1,86 @@
+package flex
+
+import (
+	gocontext "context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// pagingServer serves entities w0 to w<count-1>, never more than maxLimit at
+// a time.
+func pagingServer(count int, maxLimit int, requests *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+
+		skip, _ := strconv.Atoi(r.URL.Query().Get("skip"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if limit > maxLimit {
+			limit = maxLimit
+		}
+
+		after := -1
+		if query := r.URL.Query().Get("query"); query != "" {
+			var q struct {
+				ID struct {
+					GT string `json:"$gt"`
+				} `json:"_id"`
+			}
+			json.Unmarshal([]byte(query), &q)
+			after, _ = strconv.Atoi(q.ID.GT[1:])
+		}
+
+		page := make([]idOnly, 0)
+		for i := after + 1 + skip; i < count && len(page) < limit; i++ {
+			page = append(page, idOnly{ID: "w" + strconv.Itoa(i)})
+		}
+		body, _ := json.Marshal(page)
+		w.Write(body)
+	}))
+}
+
+func TestCursorPagesPastServerLimit(t *testing.T) {
+	for _, byID := range []bool{false, true} {
+		requests := 0
+		server := pagingServer(5, 2, &requests)
+
+		widgets := testDataStore(server.URL).NewCollection("widgets")
+		cursor := widgets.FindAll(gocontext.Background(), "", PageOptions{PageSize: 10, ByID: byID})
+
+		var ids []string
+		for cursor.Next() {
+			entity := idOnly{}
+			if err := cursor.Decode(&entity); err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, entity.ID)
+		}
+		server.Close()
+
+		if cursor.Err() != nil {
+			t.Fatalf("ByID %v: %v", byID, cursor.Err())
+		}
+		if len(ids) != 5 || ids[0] != "w0" || ids[4] != "w4" {
+			t.Fatalf("ByID %v: expected w0 to w4, got %v", byID, ids)
+		}
+		// three pages and the empty page that ends them
+		if requests != 4 {
+			t.Fatalf("ByID %v: expected 4 requests, got %d", byID, requests)
+		}
+	}
+}
+
+func TestCursorStopsOnEmptyPage(t *testing.T) {
+	requests := 0
+	server := pagingServer(0, 2, &requests)
+	defer server.Close()
+
+	cursor := testDataStore(server.URL).NewCollection("widgets").FindAll(gocontext.Background(), "", PageOptions{})
+	if cursor.Next() || cursor.Next() || cursor.Err() != nil {
+		t.Fatalf("expected no results, got error %v", cursor.Err())
+	}
+	if requests != 1 {
+		t.Fatalf("expected a single request, got %d", requests)
+	}
+}
@@ -2,6 +2,10 @@ package flex
 
 import (
 	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/timw255/flex-go/util"
 )
 
 // ServiceObject ...
@@ -15,19 +19,37 @@ type ServiceObject interface {
 	OnGetCount(functionToExecute func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task)) error
 	OnGetCountByQuery(functionToExecute func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task)) error
 	OnInsert(functionToExecute func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task)) error
+	OnInsertMany(functionToExecute func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task)) error
 	OnUpdate(functionToExecute func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task)) error
+	OnPatch(functionToExecute func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task)) error
+	Use(middleware ...Middleware)
+	SetSchema(dataOp string, schema *Schema) error
+	SetPolicy(dataOp string, policy *Policy) error
+	SetConcurrencyLimit(dataOp string, limit ConcurrencyLimit) error
+	SetRateLimit(dataOp string, limit RateLimit) error
+	EnableCache(options CacheOptions)
+	DisableCache()
+	SetDescription(description string)
+	Describe(dataOp string, metadata HandlerMetadata) error
 }
 
 type serviceObject struct {
-	name     string
-	eventMap map[string]func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task)
+	name        string
+	description string
+	mu          sync.RWMutex
+	eventMap    map[string]func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task)
+	options     map[string]handlerOptions
+	cache       *responseCache
+	middleware  *middlewareStack
 }
 
 func (so *serviceObject) register(dataOp string, functionToExecute func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task)) error {
 	if dataOp == "" {
 		return errors.New("Operation not permitted")
 	}
+	so.mu.Lock()
 	so.eventMap[dataOp] = functionToExecute
+	so.mu.Unlock()
 	return nil
 }
 
@@ -35,7 +57,9 @@ func (so *serviceObject) unregister(dataOp string) error {
 	if dataOp == "" {
 		return errors.New("Operation not permitted")
 	}
+	so.mu.Lock()
 	delete(so.eventMap, dataOp)
+	so.mu.Unlock()
 	return nil
 }
 
@@ -84,60 +108,235 @@ func (so *serviceObject) OnInsert(functionToExecute func(context *Request, compl
 	return nil
 }
 
+// OnInsertMany handles POSTs with an array body. Without it, each entity is
+// passed to the onInsert handler in turn.
+func (so *serviceObject) OnInsertMany(functionToExecute func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task)) error {
+	so.register("onInsertMany", functionToExecute)
+	return nil
+}
+
 func (so *serviceObject) OnUpdate(functionToExecute func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task)) error {
 	so.register("onUpdate", functionToExecute)
 	return nil
 }
 
+// OnPatch handles partial updates. Use context.Patch() to decode the body as a
+// JSON merge patch or JSON patch; bodies that are neither are rejected with a
+// 400 before the handler runs.
+func (so *serviceObject) OnPatch(functionToExecute func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task)) error {
+	so.register("onPatch", functionToExecute)
+	return nil
+}
+
+// Use adds middleware around the handlers of this service object only.
+func (so *serviceObject) Use(middleware ...Middleware) {
+	so.middleware.use(middleware...)
+}
+
+// SetSchema validates request bodies for dataOp against schema before the
+// handler runs. Pass a nil schema to stop validating.
+func (so *serviceObject) SetSchema(dataOp string, schema *Schema) error {
+	return so.setOptions(dataOp, func(o *handlerOptions) {
+		o.schema = schema
+	})
+}
+
+// SetPolicy restricts which callers may run dataOp. Pass a nil policy to
+// allow everyone.
+func (so *serviceObject) SetPolicy(dataOp string, policy *Policy) error {
+	return so.setOptions(dataOp, func(o *handlerOptions) {
+		o.policy = policy
+	})
+}
+
+// SetConcurrencyLimit bounds how many dataOp requests run at once.
+func (so *serviceObject) SetConcurrencyLimit(dataOp string, limit ConcurrencyLimit) error {
+	return so.setOptions(dataOp, func(o *handlerOptions) {
+		o.limiter = newConcurrencyLimiter(limit)
+	})
+}
+
+// SetRateLimit limits how often each caller may run dataOp.
+func (so *serviceObject) SetRateLimit(dataOp string, limit RateLimit) error {
+	return so.setOptions(dataOp, func(o *handlerOptions) {
+		o.rate = newRateLimiter(limit)
+	})
+}
+
+// EnableCache caches successful read responses. Any successful insert,
+// update, patch or delete on this service object empties its cache.
+func (so *serviceObject) EnableCache(options CacheOptions) {
+	so.mu.Lock()
+	so.cache = newResponseCache(options)
+	so.mu.Unlock()
+}
+
+// DisableCache ...
+func (so *serviceObject) DisableCache() {
+	so.mu.Lock()
+	so.cache = nil
+	so.mu.Unlock()
+}
+
+// SetDescription describes the service object in the discovery payload.
+func (so *serviceObject) SetDescription(description string) {
+	so.mu.Lock()
+	so.description = description
+	so.mu.Unlock()
+}
+
+// Describe sets the discovery metadata for dataOp. Only the fields set in
+// metadata are changed.
+func (so *serviceObject) Describe(dataOp string, metadata HandlerMetadata) error {
+	return so.setOptions(dataOp, func(o *handlerOptions) {
+		o.metadata = o.metadata.merge(metadata)
+	})
+}
+
+func (so *serviceObject) discover() ServiceObjectMetadata {
+	so.mu.RLock()
+	defer so.mu.RUnlock()
+
+	metadata := ServiceObjectMetadata{
+		Description: so.description,
+		Operations:  make(map[string]HandlerMetadata),
+	}
+	for dataOp := range so.eventMap {
+		metadata.Operations[dataOp] = so.options[dataOp].describe()
+	}
+	return metadata
+}
+
+func isDataOp(dataOp string) bool {
+	return util.Contains(cacheableOps, dataOp) || util.Contains(writeOps, dataOp)
+}
+
+func (so *serviceObject) setOptions(dataOp string, apply func(o *handlerOptions)) error {
+	if !isDataOp(dataOp) {
+		return errors.New("Unknown data operation " + dataOp)
+	}
+	so.mu.Lock()
+	o := so.options[dataOp]
+	apply(&o)
+	so.options[dataOp] = o
+	so.mu.Unlock()
+	return nil
+}
+
 func (so *serviceObject) RemoveHandler(dataOp string) error {
 	so.unregister(dataOp)
 	return nil
 }
 
 func (so *serviceObject) resolve(dataOp string) func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task) {
+	so.mu.RLock()
+	defer so.mu.RUnlock()
+
 	if i, ok := so.eventMap[dataOp]; ok {
 		return i
 	}
 	return KinveyNotImplementedHandler()
 }
 
+func (so *serviceObject) hasHandler(dataOp string) bool {
+	so.mu.RLock()
+	defer so.mu.RUnlock()
+
+	_, ok := so.eventMap[dataOp]
+	return ok
+}
+
+// handler resolves dataOp and wraps it in the cache, the operation's options
+// and the service object's middleware.
+func (so *serviceObject) handler(dataOp string) HandlerFunc {
+	return so.requestHandler(dataOp, so.entityHandler(dataOp))
+}
+
+// entityHandler is the handler for dataOp with its cache and the options
+// checked per entity, without middleware.
+func (so *serviceObject) entityHandler(dataOp string) HandlerFunc {
+	so.mu.RLock()
+	o := so.options[dataOp]
+	cache := so.cache
+	so.mu.RUnlock()
+
+	handler := so.resolve(dataOp)
+	if cache != nil {
+		handler = cache.wrap(so.name, dataOp, handler)
+	}
+
+	return o.wrapEntity(handler)
+}
+
+// requestHandler wraps handler in the middleware and the options for dataOp
+// that are checked once per request.
+func (so *serviceObject) requestHandler(dataOp string, handler HandlerFunc) HandlerFunc {
+	so.mu.RLock()
+	o := so.options[dataOp]
+	so.mu.RUnlock()
+
+	return so.middleware.wrap(o.wrapRequest(handler))
+}
+
 // Data ...
 type Data interface {
 	NewServiceObject(name string) ServiceObject
 	getServiceObjects() []string
+	discover() map[string]ServiceObjectMetadata
+	hasServiceObject(name string) bool
 	RemoveServiceObject(serviceObjectToRemove string) error
 	clearAll()
-	serviceObject(serviceObjectName string) serviceObject
+	serviceObject(serviceObjectName string) *serviceObject
 	process(task *Task, modules Modules) (*Task, *Task)
 }
 
 type data struct {
-	registeredServiceObjects map[string]serviceObject
+	mu                       sync.RWMutex
+	registeredServiceObjects map[string]*serviceObject
+	middleware               *middlewareStack
 }
 
-func newData() Data {
+func newData(middleware *middlewareStack) Data {
 	fd := &data{
-		registeredServiceObjects: make(map[string]serviceObject),
+		registeredServiceObjects: make(map[string]*serviceObject),
+		middleware:               middleware,
 	}
 	return fd
 }
 
-// NewServiceObject ...
+// reservedServiceObjectNames are the top-level paths the HTTP receiver routes
+// to something other than a service object.
+var reservedServiceObjectNames = []string{"healthcheck", "_command", "_flexFunctions", "_auth"}
+
+// NewServiceObject registers a service object. It panics if name is one of
+// the reserved paths healthcheck, _command, _flexFunctions or _auth, whose
+// requests would never reach it.
 func (fd *data) NewServiceObject(name string) ServiceObject {
-	so := fd.newServiceObject(name)
-	return &so
+	if util.Contains(reservedServiceObjectNames, name) {
+		panic(fmt.Sprintf("flex: %q is reserved and cannot be used as a service object name", name))
+	}
+	return fd.newServiceObject(name)
 }
 
-func (fd *data) newServiceObject(name string) serviceObject {
-	so := serviceObject{
-		name: name,
+func (fd *data) newServiceObject(name string) *serviceObject {
+	so := &serviceObject{
+		name:       name,
+		options:    make(map[string]handlerOptions),
+		middleware: newMiddlewareStack(),
 	}
 	so.eventMap = make(map[string]func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task))
+
+	fd.mu.Lock()
 	fd.registeredServiceObjects[name] = so
+	fd.mu.Unlock()
+
 	return so
 }
 
 func (fd *data) getServiceObjects() []string {
+	fd.mu.RLock()
+	defer fd.mu.RUnlock()
+
 	keys := make([]string, 0)
 	for key := range fd.registeredServiceObjects {
 		keys = append(keys, key)
@@ -145,10 +344,27 @@ func (fd *data) getServiceObjects() []string {
 	return keys
 }
 
-func (fd *data) serviceObject(serviceObjectName string) serviceObject {
-	if _, ok := fd.registeredServiceObjects[serviceObjectName]; !ok {
-		fd.registeredServiceObjects[serviceObjectName] = fd.newServiceObject(serviceObjectName)
+func (fd *data) discover() map[string]ServiceObjectMetadata {
+	fd.mu.RLock()
+	defer fd.mu.RUnlock()
+
+	metadata := make(map[string]ServiceObjectMetadata)
+	for name, so := range fd.registeredServiceObjects {
+		metadata[name] = so.discover()
 	}
+	return metadata
+}
+
+func (fd *data) hasServiceObject(name string) bool {
+	return fd.serviceObject(name) != nil
+}
+
+// serviceObject looks up a registered service object, returning nil when
+// none is registered under that name.
+func (fd *data) serviceObject(serviceObjectName string) *serviceObject {
+	fd.mu.RLock()
+	defer fd.mu.RUnlock()
+
 	return fd.registeredServiceObjects[serviceObjectName]
 }
 
@@ -156,10 +372,21 @@ func (fd *data) process(task *Task, modules Modules) (*Task, *Task) {
 	serviceObjectToProcess := fd.serviceObject(task.Request.ServiceObjectName)
 
 	var dataOp string
-	if task.Method == "POST" {
+	if task.Method == "POST" && isJSONArray(task.Request.Body) {
+		if serviceObjectToProcess != nil && !serviceObjectToProcess.hasHandler("onInsertMany") {
+			return fd.insertEach(serviceObjectToProcess, task, modules)
+		}
+		dataOp = "onInsertMany"
+	} else if task.Method == "POST" {
 		dataOp = "onInsert"
 	} else if task.Method == "PUT" {
 		dataOp = "onUpdate"
+	} else if task.Method == "PATCH" && task.Request.EntityID != "" {
+		if _, err := DecodePatch(task.Request.Body); err != nil {
+			dataCompletionHandler := NewKinveyCompletionHandler(task)
+			return dataCompletionHandler.BadRequest(err.Error()).Done()
+		}
+		dataOp = "onPatch"
 	} else if task.Method == "GET" && task.Endpoint != "_count" {
 		taskRequest := task.Request
 		if taskRequest.EntityID != "" {
@@ -186,10 +413,18 @@ func (fd *data) process(task *Task, modules Modules) (*Task, *Task) {
 			dataOp = "onDeleteAll"
 		}
 	} else {
-		// 'BadRequest', 'Cannot determine data operation'
+		dataCompletionHandler := NewKinveyCompletionHandler(task)
+		return dataCompletionHandler.BadRequest("Cannot determine data operation").Done()
+	}
+
+	var operationHandler HandlerFunc
+	if serviceObjectToProcess != nil {
+		operationHandler = serviceObjectToProcess.handler(dataOp)
+	} else {
+		operationHandler = KinveyNotImplementedHandler()
 	}
+	operationHandler = fd.middleware.wrap(operationHandler)
 
-	operationHandler := serviceObjectToProcess.resolve(dataOp)
 	dataCompletionHandler := NewKinveyCompletionHandler(task)
 	return operationHandler(&task.Request, dataCompletionHandler, modules)
 }
@@ -199,10 +434,14 @@ func (fd *data) RemoveServiceObject(serviceObjectToRemove string) error {
 	if serviceObjectToRemove == "" {
 		return errors.New("Must list ServiceObject name")
 	}
+	fd.mu.Lock()
 	delete(fd.registeredServiceObjects, serviceObjectToRemove)
+	fd.mu.Unlock()
 	return nil
 }
 
 func (fd *data) clearAll() {
-	fd.registeredServiceObjects = make(map[string]serviceObject)
+	fd.mu.Lock()
+	fd.registeredServiceObjects = make(map[string]*serviceObject)
+	fd.mu.Unlock()
 }
@@ -2,11 +2,16 @@ package flex
 
 import (
 	"bytes"
+	gocontext "context"
 	"errors"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
 )
 
 // DataStoreModule ...
@@ -14,13 +19,15 @@ type DataStoreModule struct {
 	appMetadata    kinveyAppMetadata
 	requestContext RequestMetadata
 	taskMetadata   TaskMetadata
+	baas           *baasClient
 }
 
-func newDataStoreModule(appMetadata kinveyAppMetadata, requestMetadata RequestMetadata, taskMetadata TaskMetadata) DataStoreModule {
+func newDataStoreModule(appMetadata kinveyAppMetadata, requestMetadata RequestMetadata, taskMetadata TaskMetadata, baas *baasClient) DataStoreModule {
 	return DataStoreModule{
 		appMetadata:    appMetadata,
 		requestContext: requestMetadata,
 		taskMetadata:   taskMetadata,
+		baas:           baas,
 	}
 }
 
@@ -34,7 +41,7 @@ func (m DataStoreModule) NewDataStore(useBL bool, useUserContext bool) DataStore
 	s.useBL = useBL
 	s.useUserContext = useUserContext
 
-	s.client = &http.Client{}
+	s.baas = m.baas
 
 	return s
 }
@@ -46,23 +53,55 @@ type baseStore struct {
 	useBL          bool
 	useUserContext bool
 
-	client *http.Client
+	baas *baasClient
 }
 
+// ErrNotFound is returned from FindOne when nothing matches. The RequestError
+// for a 404, such as from FindByID, matches it with errors.Is.
+var ErrNotFound = errors.New("Entity not found")
+
+// makeRequest returns the response body, or a RequestError for an error
+// status.
 func (bs baseStore) makeRequest(req *http.Request) ([]byte, error) {
-	resp, err := bs.client.Do(req)
+	resp, err := bs.baas.do(req)
 	if err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
 	}
 
+	if resp.StatusCode >= 400 {
+		return nil, newRequestError(resp.StatusCode, body)
+	}
+
 	return body, nil
 }
 
+// makeStreamRequest returns the response body unread, or a RequestError for
+// an error status. The caller must close the body.
+func (bs baseStore) makeStreamRequest(req *http.Request) (io.ReadCloser, error) {
+	resp, err := bs.baas.stream(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+
+		body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		if err != nil {
+			return nil, err
+		}
+		return nil, newRequestError(resp.StatusCode, body)
+	}
+
+	return resp.Body, nil
+}
+
 func (bs baseStore) buildKinveyRequest(baseRoute string, collection string, useAppSecret bool, useUserContext bool) (*http.Request, error) {
 	if baseRoute == "" {
 		return nil, errors.New("Missing Base Route")
@@ -115,13 +154,27 @@ func (s DataStore) buildAppDataRequest(collectionName string) (*http.Request, er
 	return s.buildKinveyRequest(baseRoute, collectionName, false, s.useUserContext)
 }
 
-func (s DataStore) makeAppDataRequest(req *http.Request, collectionName string) ([]byte, error) {
+func (s DataStore) checkAppDataRequest(collectionName string) error {
 	if s.taskMetadata.ObjectName == collectionName && (s.useBL || s.useUserContext) {
-		return nil, errors.New("Not Allowed")
+		return errors.New("Not Allowed")
+	}
+	return nil
+}
+
+func (s DataStore) makeAppDataRequest(req *http.Request, collectionName string) ([]byte, error) {
+	if err := s.checkAppDataRequest(collectionName); err != nil {
+		return nil, err
 	}
 	return s.makeRequest(req)
 }
 
+func (s DataStore) makeAppDataStreamRequest(req *http.Request, collectionName string) (io.ReadCloser, error) {
+	if err := s.checkAppDataRequest(collectionName); err != nil {
+		return nil, err
+	}
+	return s.makeStreamRequest(req)
+}
+
 // Entity ...
 type Entity interface {
 	GetID() *string
@@ -130,13 +183,58 @@ type Entity interface {
 // Collection ...
 type Collection interface {
 	Find(query string) ([]byte, error)
+	FindWithOptions(query string, options FindOptions) ([]byte, error)
+	FindAll(ctx gocontext.Context, query string, options PageOptions) *Cursor
+	FindOne(query string, options FindOptions) ([]byte, error)
 	FindByID(id string) ([]byte, error)
+	Group(aggregation Aggregation) ([]byte, error)
+	Distinct(field string, query string) ([]byte, error)
+	Insert(entity interface{}) ([]byte, error)
+	Update(id string, entity interface{}) ([]byte, error)
 	Save(entity Entity) ([]byte, error)
 	Remove(query string) (int, error)
 	RemoveByID(id string) (int, error)
 	Count(query string) (int, error)
 }
 
+// FindOptions ...
+type FindOptions struct {
+	Fields []string
+	Sort   string
+	Limit  int
+	Skip   int
+}
+
+func (o FindOptions) apply(req *http.Request, query string) {
+	q := req.URL.Query()
+
+	if query != "" {
+		q.Add("query", query)
+	}
+	if len(o.Fields) > 0 {
+		q.Add("fields", strings.Join(o.Fields, ","))
+	}
+	if o.Sort != "" {
+		q.Add("sort", o.Sort)
+	}
+	if o.Limit > 0 {
+		q.Add("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Skip > 0 {
+		q.Add("skip", strconv.Itoa(o.Skip))
+	}
+
+	req.URL.RawQuery = q.Encode()
+}
+
+// Aggregation ...
+type Aggregation struct {
+	Key       map[string]bool        `json:"key"`
+	Initial   map[string]interface{} `json:"initial"`
+	Reduce    string                 `json:"reduce"`
+	Condition map[string]interface{} `json:"condition,omitempty"`
+}
+
 type collection struct {
 	dataStore      DataStore
 	collectionName string
@@ -147,22 +245,115 @@ type countResponse struct {
 }
 
 func (c collection) Find(query string) ([]byte, error) {
+	return c.FindWithOptions(query, FindOptions{})
+}
+
+func (c collection) FindWithOptions(query string, options FindOptions) ([]byte, error) {
+	return c.find(gocontext.Background(), query, options)
+}
+
+func (c collection) find(ctx gocontext.Context, query string, options FindOptions) ([]byte, error) {
 	requestOptions, err := c.dataStore.buildAppDataRequest(c.collectionName)
 	if err != nil {
 		return nil, err
 	}
 
+	requestOptions = requestOptions.WithContext(ctx)
 	requestOptions.Method = "GET"
 
-	if query != "" {
-		q := requestOptions.URL.Query()
-		q.Add("query", query)
-		requestOptions.URL.RawQuery = q.Encode()
+	options.apply(requestOptions, query)
+
+	return c.dataStore.makeAppDataRequest(requestOptions, c.collectionName)
+}
+
+func (c collection) FindOne(query string, options FindOptions) ([]byte, error) {
+	options.Limit = 1
+
+	kinveyResponse, err := c.FindWithOptions(query, options)
+	if err != nil {
+		return nil, err
 	}
 
+	entities := make([]jsoniter.RawMessage, 0)
+	err = json.Unmarshal(kinveyResponse, &entities)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(entities) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return entities[0], nil
+}
+
+func (c collection) Group(aggregation Aggregation) ([]byte, error) {
+	requestOptions, err := c.dataStore.buildAppDataRequest(c.collectionName)
+	if err != nil {
+		return nil, err
+	}
+
+	requestOptions.Method = "POST"
+
+	u, err := url.Parse(requestOptions.URL.String() + "_group")
+	if err != nil {
+		return nil, err
+	}
+
+	requestOptions.URL = u
+
+	if aggregation.Initial == nil {
+		aggregation.Initial = make(map[string]interface{})
+	}
+
+	json, err := json.Marshal(aggregation)
+	if err != nil {
+		return nil, err
+	}
+
+	requestOptions.Body = ioutil.NopCloser(bytes.NewReader(json))
+
 	return c.dataStore.makeAppDataRequest(requestOptions, c.collectionName)
 }
 
+func (c collection) Distinct(field string, query string) ([]byte, error) {
+	if field == "" {
+		return nil, errors.New("field is required")
+	}
+
+	aggregation := Aggregation{
+		Key:    map[string]bool{field: true},
+		Reduce: "function(doc, out) {}",
+	}
+
+	if query != "" {
+		err := json.Unmarshal([]byte(query), &aggregation.Condition)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	kinveyResponse, err := c.Group(aggregation)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]map[string]jsoniter.RawMessage, 0)
+	err = json.Unmarshal(kinveyResponse, &groups)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]jsoniter.RawMessage, 0, len(groups))
+	for _, g := range groups {
+		if v, ok := g[field]; ok {
+			values = append(values, v)
+		}
+	}
+
+	return json.Marshal(values)
+}
+
 func (c collection) FindByID(id string) ([]byte, error) {
 	if id == "" {
 		return nil, errors.New("id is required")
@@ -175,7 +366,7 @@ func (c collection) FindByID(id string) ([]byte, error) {
 
 	requestOptions.Method = "GET"
 
-	u, err := url.Parse(requestOptions.URL.String() + id)
+	u, err := url.Parse(requestOptions.URL.String() + url.PathEscape(id))
 	if err != nil {
 		return nil, err
 	}
@@ -185,25 +376,43 @@ func (c collection) FindByID(id string) ([]byte, error) {
 	return c.dataStore.makeAppDataRequest(requestOptions, c.collectionName)
 }
 
-func (c collection) Save(entity Entity) ([]byte, error) {
+func (c collection) Insert(entity interface{}) ([]byte, error) {
 	requestOptions, err := c.dataStore.buildAppDataRequest(c.collectionName)
 	if err != nil {
 		return nil, err
 	}
 
-	if entity.GetID() != nil {
-		requestOptions.Method = "PUT"
+	requestOptions.Method = "POST"
 
-		u, err := url.Parse(requestOptions.URL.String() + *entity.GetID())
-		if err != nil {
-			return nil, err
-		}
+	json, err := json.Marshal(entity)
+	if err != nil {
+		return nil, err
+	}
 
-		requestOptions.URL = u
-	} else {
-		requestOptions.Method = "POST"
+	requestOptions.Body = ioutil.NopCloser(bytes.NewReader(json))
+
+	return c.dataStore.makeAppDataRequest(requestOptions, c.collectionName)
+}
+
+func (c collection) Update(id string, entity interface{}) ([]byte, error) {
+	if id == "" {
+		return nil, errors.New("id is required")
 	}
 
+	requestOptions, err := c.dataStore.buildAppDataRequest(c.collectionName)
+	if err != nil {
+		return nil, err
+	}
+
+	requestOptions.Method = "PUT"
+
+	u, err := url.Parse(requestOptions.URL.String() + url.PathEscape(id))
+	if err != nil {
+		return nil, err
+	}
+
+	requestOptions.URL = u
+
 	json, err := json.Marshal(entity)
 	if err != nil {
 		return nil, err
@@ -214,6 +423,14 @@ func (c collection) Save(entity Entity) ([]byte, error) {
 	return c.dataStore.makeAppDataRequest(requestOptions, c.collectionName)
 }
 
+// Save inserts entity when it has no ID and updates it otherwise.
+func (c collection) Save(entity Entity) ([]byte, error) {
+	if entity.GetID() != nil {
+		return c.Update(*entity.GetID(), entity)
+	}
+	return c.Insert(entity)
+}
+
 func (c collection) Remove(query string) (int, error) {
 	requestOptions, err := c.dataStore.buildAppDataRequest(c.collectionName)
 	if err != nil {
@@ -254,7 +471,7 @@ func (c collection) RemoveByID(id string) (int, error) {
 
 	requestOptions.Method = "DELETE"
 
-	u, err := url.Parse(requestOptions.URL.String() + id)
+	u, err := url.Parse(requestOptions.URL.String() + url.PathEscape(id))
 	if err != nil {
 		return 0, err
 	}
@@ -0,0 +1,94 @@
+package flex
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func testDataStore(baasURL string) DataStore {
+	return DataStore{baseStore{
+		appMetadata: kinveyAppMetadata{ID: "kid_abc123", BaaSURL: baasURL},
+		baas:        testBaaSClient(RetryPolicy{}, CircuitBreakerPolicy{}),
+	}}
+}
+
+func TestCollectionErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/appdata/kid_abc123/widgets/missing":
+			w.WriteHeader(404)
+			w.Write([]byte(`{"error":"EntityNotFound","description":"This entity not found in the collection"}`))
+		case "/appdata/kid_abc123/widgets/_count":
+			w.WriteHeader(401)
+			w.Write([]byte(`{"error":"InsufficientCredentials","description":"The credentials used to authenticate this request are not authorized"}`))
+		case "/appdata/kid_abc123/widgets/":
+			w.Write([]byte(`[]`))
+		default:
+			w.WriteHeader(500)
+			w.Write([]byte(`not json`))
+		}
+	}))
+	defer server.Close()
+
+	widgets := testDataStore(server.URL).NewCollection("widgets")
+
+	if _, err := widgets.FindOne(`{"name":"widget"}`, FindOptions{}); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound from FindOne with no match, got %v", err)
+	}
+
+	_, err := widgets.FindByID("missing")
+	var requestErr *RequestError
+	if !errors.As(err, &requestErr) || requestErr.StatusCode != 404 || requestErr.KinveyError.Error != "EntityNotFound" {
+		t.Fatalf("expected a 404 RequestError, got %v", err)
+	}
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected a 404 to match ErrNotFound, got %v", err)
+	}
+
+	// counts of error bodies used to come back as 0
+	if count, err := widgets.Count(""); err == nil || errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected a 401 error, got %d, %v", count, err)
+	}
+
+	_, err = widgets.FindByID("broken")
+	if !errors.As(err, &requestErr) || requestErr.StatusCode != 500 || string(requestErr.Body) != "not json" {
+		t.Fatalf("expected a 500 RequestError with the raw body, got %v", err)
+	}
+	if err.Error() != "Kinvey request failed with status 500" {
+		t.Fatalf("unexpected message %q", err.Error())
+	}
+}
+
+func TestCollectionEscapesIDs(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.EscapedPath()+"?"+r.URL.RawQuery)
+		w.Write([]byte(`{"count":1}`))
+	}))
+	defer server.Close()
+
+	widgets := testDataStore(server.URL).NewCollection("widgets")
+	id := "a/b?c#d"
+
+	widgets.FindByID(id)
+	widgets.Update(id, map[string]string{"name": "widget"})
+	widgets.Save(&KinveyEntity{ID: &id})
+	widgets.RemoveByID(id)
+
+	want := []string{
+		"GET /appdata/kid_abc123/widgets/a%2Fb%3Fc%23d?",
+		"PUT /appdata/kid_abc123/widgets/a%2Fb%3Fc%23d?",
+		"PUT /appdata/kid_abc123/widgets/a%2Fb%3Fc%23d?",
+		"DELETE /appdata/kid_abc123/widgets/a%2Fb%3Fc%23d?",
+	}
+	if len(paths) != len(want) {
+		t.Fatalf("expected %v, got %v", want, paths)
+	}
+	for i := range want {
+		if paths[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, paths)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package flex
+
+import (
+	"net/url"
+	"testing"
+)
+
+func benchmarkData() Data {
+	fd := newData(newMiddlewareStack())
+
+	respond := func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task) {
+		return complete.SetBody([]byte(`{}`)).OK().Done()
+	}
+
+	widgets := fd.NewServiceObject("widgets")
+	widgets.OnGetAll(respond)
+	widgets.OnGetByID(respond)
+	widgets.OnGetByQuery(respond)
+	widgets.OnInsert(respond)
+
+	return fd
+}
+
+func benchmarkProcess(b *testing.B, task Task) {
+	fd := benchmarkData()
+	baas := newBaaSClient(NewOptions("", 10001, ""))
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		t := task
+		fd.process(&t, generateModules(&t, baas))
+	}
+}
+
+func BenchmarkDataProcessGetAll(b *testing.B) {
+	benchmarkProcess(b, Task{
+		Method:  "GET",
+		Request: Request{ServiceObjectName: "widgets"},
+	})
+}
+
+func BenchmarkDataProcessGetByID(b *testing.B) {
+	benchmarkProcess(b, Task{
+		Method:  "GET",
+		Request: Request{ServiceObjectName: "widgets", EntityID: "123"},
+	})
+}
+
+func BenchmarkDataProcessGetByQuery(b *testing.B) {
+	benchmarkProcess(b, Task{
+		Method:  "GET",
+		Request: Request{ServiceObjectName: "widgets", Query: url.Values{"query": []string{`{"name":"widget"}`}}},
+	})
+}
+
+func BenchmarkDataProcessInsert(b *testing.B) {
+	benchmarkProcess(b, Task{
+		Method:  "POST",
+		Request: Request{ServiceObjectName: "widgets", Body: []byte(`{"name":"widget"}`)},
+	})
+}
+
+func BenchmarkDataProcessNotImplemented(b *testing.B) {
+	benchmarkProcess(b, Task{
+		Method:  "DELETE",
+		Request: Request{ServiceObjectName: "widgets"},
+	})
+}
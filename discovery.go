@@ -0,0 +1,40 @@
+package flex
+
+// HandlerMetadata describes a data operation, function or auth handler in the
+// service discovery payload.
+type HandlerMetadata struct {
+	Description  string  `json:"description,omitempty"`
+	InputSchema  *Schema `json:"inputSchema,omitempty"`
+	OutputSchema *Schema `json:"outputSchema,omitempty"`
+}
+
+// ServiceObjectMetadata describes a service object and the data operations
+// it implements in the service discovery payload.
+type ServiceObjectMetadata struct {
+	Description string                     `json:"description,omitempty"`
+	Operations  map[string]HandlerMetadata `json:"operations"`
+}
+
+// merge overwrites the fields that are set in m.
+func (hm HandlerMetadata) merge(m HandlerMetadata) HandlerMetadata {
+	if m.Description != "" {
+		hm.Description = m.Description
+	}
+	if m.InputSchema != nil {
+		hm.InputSchema = m.InputSchema
+	}
+	if m.OutputSchema != nil {
+		hm.OutputSchema = m.OutputSchema
+	}
+	return hm
+}
+
+// describe returns the handler's metadata, falling back to its validation
+// schema when no input schema was given.
+func (o handlerOptions) describe() HandlerMetadata {
+	metadata := o.metadata
+	if metadata.InputSchema == nil {
+		metadata.InputSchema = o.schema
+	}
+	return metadata
+}
@@ -0,0 +1,85 @@
+package flex
+
+import (
+	"testing"
+	"time"
+)
+
+type discoveryWidget struct {
+	KinveyEntity
+	Name     string            `json:"name" description:"Display name"`
+	Tags     []string          `json:"tags,omitempty"`
+	Price    float64           `json:"price"`
+	Count    int               `json:"count"`
+	Created  time.Time         `json:"created"`
+	Parent   *discoveryWidget  `json:"parent,omitempty"`
+	Extra    map[string]string `json:"extra"`
+	Ignored  string            `json:"-"`
+	internal string
+}
+
+func TestSchemaFor(t *testing.T) {
+	s := SchemaFor[discoveryWidget]()
+
+	if s.Type != "object" {
+		t.Fatalf("got type %q", s.Type)
+	}
+
+	want := map[string]string{
+		"_id":     "string",
+		"_acl":    "object",
+		"_kmd":    "object",
+		"name":    "string",
+		"tags":    "array",
+		"price":   "number",
+		"count":   "integer",
+		"created": "string",
+		"parent":  "object",
+		"extra":   "object",
+	}
+	if len(s.Properties) != len(want) {
+		t.Errorf("got %d properties, want %d", len(s.Properties), len(want))
+	}
+	for name, typ := range want {
+		if p, ok := s.Properties[name]; !ok || p.Type != typ {
+			t.Errorf("property %s: got %+v, want type %s", name, p, typ)
+		}
+	}
+
+	if s.Properties["name"].Description != "Display name" {
+		t.Errorf("description tag was not used")
+	}
+	if s.Properties["tags"].Items.Type != "string" {
+		t.Errorf("array items were not described")
+	}
+	if s.Properties["parent"].Properties != nil {
+		t.Errorf("recursive type was expanded")
+	}
+}
+
+func TestDiscoveryMetadata(t *testing.T) {
+	s := newFlex(NewOptions("", 10001, ""))
+
+	widgets := s.Data.NewServiceObject("widgets")
+	widgets.SetDescription("Widgets from the warehouse")
+	OnInsertTyped(widgets, func(context *Request, entity discoveryWidget, modules Modules) (discoveryWidget, error) {
+		return entity, nil
+	})
+	widgets.Describe("onInsert", HandlerMetadata{Description: "Adds a widget"})
+	widgets.SetSchema("onGetAll", nil)
+
+	_, result := s.processTask(&Task{TaskType: "serviceDiscovery"})
+	metadata := result.DiscoveryObjects.DataLink.Metadata["widgets"]
+
+	if metadata.Description != "Widgets from the warehouse" {
+		t.Errorf("got description %q", metadata.Description)
+	}
+	if len(metadata.Operations) != 1 {
+		t.Fatalf("got operations %v, want only onInsert", metadata.Operations)
+	}
+
+	insert := metadata.Operations["onInsert"]
+	if insert.Description != "Adds a widget" || insert.InputSchema == nil || insert.OutputSchema == nil {
+		t.Errorf("onInsert metadata was not kept: %+v", insert)
+	}
+}
@@ -11,7 +11,7 @@ import (
 // EmailModule ...
 type EmailModule struct {
 	appMetadata kinveyAppMetadata
-	client      *http.Client
+	baas        *baasClient
 	baseRoute   string
 }
 
@@ -20,10 +20,10 @@ type emailResponse struct {
 	mailServerResponse string
 }
 
-func newEmailModule(appMetadata kinveyAppMetadata) EmailModule {
+func newEmailModule(appMetadata kinveyAppMetadata, baas *baasClient) EmailModule {
 	return EmailModule{
 		appMetadata: appMetadata,
-		client:      &http.Client{},
+		baas:        baas,
 		baseRoute:   "rpc",
 	}
 }
@@ -55,10 +55,11 @@ func (m EmailModule) Send(email Email) (string, error) {
 }
 
 func (m EmailModule) makeRequest(req *http.Request) (string, error) {
-	resp, err := m.client.Do(req)
+	resp, err := m.baas.do(req)
 	if err != nil {
 		return "", err
 	}
+	defer resp.Body.Close()
 
 	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 26214400))
 	if err != nil {
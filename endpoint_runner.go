@@ -13,13 +13,15 @@ type EndpointRunnerModule struct {
 	appMetadata    kinveyAppMetadata
 	requestContext RequestMetadata
 	taskMetadata   TaskMetadata
+	baas           *baasClient
 }
 
-func newEndpointRunnerModule(appMetadata kinveyAppMetadata, requestMetadata RequestMetadata, taskMetadata TaskMetadata) EndpointRunnerModule {
+func newEndpointRunnerModule(appMetadata kinveyAppMetadata, requestMetadata RequestMetadata, taskMetadata TaskMetadata, baas *baasClient) EndpointRunnerModule {
 	return EndpointRunnerModule{
 		appMetadata:    appMetadata,
 		requestContext: requestMetadata,
 		taskMetadata:   taskMetadata,
+		baas:           baas,
 	}
 }
 
@@ -33,7 +35,7 @@ func (m EndpointRunnerModule) NewEndpointRunner(useUserContext bool) EndpointRun
 	er.useBL = true
 	er.useUserContext = useUserContext
 
-	er.client = &http.Client{}
+	er.baas = m.baas
 
 	return er
 }
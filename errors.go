@@ -1,8 +1,47 @@
 package flex
 
-// KinveyError ...
+import (
+	"fmt"
+)
+
+// KinveyError is the error body Kinvey expects from a Flex service, with the
+// error, description and debug members. StatusCode is sent as the response
+// status rather than in the body.
 type KinveyError struct {
-	Error       string
-	Description string
+	Error       string `json:"error"`
+	Description string `json:"description"`
+	Debug       string `json:"debug"`
+	StatusCode  int    `json:"-"`
+}
+
+// RequestError is returned by the stores for Kinvey responses with an error
+// status. KinveyError is filled in when the body is a Kinvey error, and Body
+// holds the body as it was received. A 404 matches ErrNotFound with
+// errors.Is.
+type RequestError struct {
 	StatusCode  int
+	KinveyError KinveyError
+	Body        []byte
+}
+
+func newRequestError(statusCode int, body []byte) *RequestError {
+	e := &RequestError{StatusCode: statusCode, Body: body}
+	json.Unmarshal(body, &e.KinveyError)
+	e.KinveyError.StatusCode = statusCode
+	return e
+}
+
+func (e *RequestError) Error() string {
+	if e.KinveyError.Error == "" {
+		return fmt.Sprintf("Kinvey request failed with status %d", e.StatusCode)
+	}
+	if e.KinveyError.Description == "" {
+		return fmt.Sprintf("Kinvey request failed with status %d: %s", e.StatusCode, e.KinveyError.Error)
+	}
+	return fmt.Sprintf("Kinvey request failed with status %d: %s: %s", e.StatusCode, e.KinveyError.Error, e.KinveyError.Description)
+}
+
+// Is ...
+func (e *RequestError) Is(target error) bool {
+	return target == ErrNotFound && e.StatusCode == 404
 }
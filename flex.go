@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -16,6 +17,9 @@ const (
 	flexGoVersion    = "0.0.0"
 	receiverTypeHTTP = "http"
 	receiverTypeTCP  = "tcp"
+	// receiverTypeReplay replays the recording in SDK_REPLAY_FILE through
+	// the service instead of receiving tasks, then exits.
+	receiverTypeReplay = "replay"
 )
 
 var (
@@ -41,20 +45,85 @@ type Options struct {
 	port         int
 	sharedSecret string
 	receiverType string
+	replayFile   string
+	replayIgnore []string
+
+	transport            TransportOptions
+	requestTimeout       time.Duration
+	retryPolicy          RetryPolicy
+	circuitBreakerPolicy CircuitBreakerPolicy
+
+	concurrencyLimits map[string]ConcurrencyLimit
+	recording         RecordingOptions
+	openAPI           OpenAPIOptions
 }
 
 // NewOptions ...
 func NewOptions(host string, port int, sharedSecret string) *Options {
 	o := &Options{
-		host,
-		port,
-		sharedSecret,
-		"http",
+		host:                 host,
+		port:                 port,
+		sharedSecret:         sharedSecret,
+		receiverType:         "http",
+		transport:            defaultTransportOptions(),
+		requestTimeout:       defaultRequestTimeout,
+		retryPolicy:          defaultRetryPolicy(),
+		circuitBreakerPolicy: defaultCircuitBreakerPolicy(),
+		concurrencyLimits:    make(map[string]ConcurrencyLimit),
 	}
 
 	return o
 }
 
+// SetTransport configures the HTTP transport that every module uses to reach
+// the Kinvey backend. Fields left at zero keep their defaults.
+func (o *Options) SetTransport(transport TransportOptions) *Options {
+	o.transport = transport.withDefaults()
+	return o
+}
+
+// SetRequestTimeout sets the timeout for each attempt of a request modules
+// make to the Kinvey backend, including reading the response. Streamed
+// results, such as FindEach, are only timed until the response starts. 0
+// means no timeout.
+func (o *Options) SetRequestTimeout(timeout time.Duration) *Options {
+	o.requestTimeout = timeout
+	return o
+}
+
+// SetRetryPolicy ...
+func (o *Options) SetRetryPolicy(policy RetryPolicy) *Options {
+	o.retryPolicy = policy
+	return o
+}
+
+// SetCircuitBreaker ...
+func (o *Options) SetCircuitBreaker(policy CircuitBreakerPolicy) *Options {
+	o.circuitBreakerPolicy = policy
+	return o
+}
+
+// SetConcurrencyLimit bounds how many tasks of taskType ("data", "functions"
+// or "auth") run at once, so a burst of one kind of task cannot starve the
+// others.
+func (o *Options) SetConcurrencyLimit(taskType string, limit ConcurrencyLimit) *Options {
+	o.concurrencyLimits[taskType] = limit
+	return o
+}
+
+// SetRecording records every task the service receives, with its response,
+// so production issues can be replayed locally. See RecordingOptions.
+func (o *Options) SetRecording(recording RecordingOptions) *Options {
+	o.recording = recording
+	return o
+}
+
+// SetOpenAPI sets the title, version and servers of the OpenAPI document.
+func (o *Options) SetOpenAPI(openAPI OpenAPIOptions) *Options {
+	o.openAPI = openAPI
+	return o
+}
+
 // Flex ...
 type Flex struct {
 	Data         Data
@@ -63,6 +132,11 @@ type Flex struct {
 	Logger       Logger
 	version      string
 	sharedSecret string
+	middleware   *middlewareStack
+	baas         *baasClient
+	limiters     map[string]*concurrencyLimiter
+	recorder     *recorder
+	openAPI      OpenAPIOptions
 }
 
 // NewService ...
@@ -71,6 +145,12 @@ func NewService(options *Options, initializer func(err error, flex Flex)) {
 
 	if ok && sdkReceiver == receiverTypeTCP {
 		options.receiverType = receiverTypeTCP
+	} else if ok && sdkReceiver == receiverTypeReplay {
+		options.receiverType = receiverTypeReplay
+		options.replayFile = os.Getenv("SDK_REPLAY_FILE")
+		if ignore := os.Getenv("SDK_REPLAY_IGNORE"); ignore != "" {
+			options.replayIgnore = strings.Split(ignore, ",")
+		}
 	} else {
 		options.receiverType = receiverTypeHTTP
 	}
@@ -79,66 +159,9 @@ func NewService(options *Options, initializer func(err error, flex Flex)) {
 
 	rec = newReceiver(options)
 
-	d := newData()
-	f := newFunctions()
-	a := newAuth()
-	l := newLogger()
-
-	s := Flex{
-		Data:         d,
-		Functions:    f,
-		Auth:         a,
-		Logger:       l,
-		version:      flexGoVersion,
-		sharedSecret: options.sharedSecret,
-	}
-
-	taskReceivedCallback := func(task *Task) (*Task, *Task) {
-		task.SDKVersion = flexGoVersion
-
-		if options.sharedSecret != "" && task.TaskType != "serviceDiscovery" && task.TaskType != "logger" && task.TaskType != "moduleGenerator" && options.sharedSecret != task.AuthKey {
-			return nil, task
-		}
-
-		if !util.Contains(flexTaskTypes, task.TaskType) {
-			return nil, task
-		}
-
-		if task.TaskType == "serviceDiscovery" {
-			so := dataLink{
-				ServiceObjects: s.Data.getServiceObjects(),
-			}
-			fh := businessLogic{
-				Handlers: s.Functions.getHandlers(),
-			}
-			ah := authDiscovery{
-				Handlers: s.Auth.getHandlers(),
-			}
-
-			dco := discoveryObjects{
-				DataLink:      so,
-				BusinessLogic: fh,
-				Auth:          ah,
-			}
+	s := newFlex(options)
 
-			task.DiscoveryObjects = dco
-
-			return nil, task
-		}
-
-		modules := generateModules(task)
-
-		switch task.TaskType {
-		case "data":
-			return s.Data.process(task, modules)
-		case "functions":
-			return s.Functions.process(task, modules)
-		case "auth":
-			return s.Auth.process(task, modules)
-		}
-
-		return nil, nil
-	}
+	taskReceivedCallback := s.processTask
 
 	var gracefulShutdown = make(chan os.Signal)
 	signal.Notify(gracefulShutdown, syscall.SIGTERM)
@@ -161,6 +184,9 @@ func NewService(options *Options, initializer func(err error, flex Flex)) {
 			if err != nil {
 				terminate(err)
 			}
+			if s.recorder != nil {
+				s.recorder.Close()
+			}
 			c <- true
 		}()
 		select {
@@ -176,11 +202,117 @@ func NewService(options *Options, initializer func(err error, flex Flex)) {
 
 	initializer(nil, s)
 
-	rec.Start(s, taskReceivedCallback, "")
+	if err := rec.Start(s, taskReceivedCallback, ""); err != nil {
+		terminate(err)
+	}
 
 	return
 }
 
+func newFlex(options *Options) Flex {
+	m := newMiddlewareStack()
+
+	limiters := make(map[string]*concurrencyLimiter)
+	for taskType, limit := range options.concurrencyLimits {
+		if limiter := newConcurrencyLimiter(limit); limiter != nil {
+			limiters[taskType] = limiter
+		}
+	}
+
+	return Flex{
+		Data:         newData(m),
+		Functions:    newFunctions(m),
+		Auth:         newAuth(m),
+		Logger:       newLogger(),
+		version:      flexGoVersion,
+		sharedSecret: options.sharedSecret,
+		middleware:   m,
+		baas:         newBaaSClient(options),
+		limiters:     limiters,
+		recorder:     newRecorder(options.recording),
+		openAPI:      options.openAPI,
+	}
+}
+
+// Use adds middleware around every data, functions and auth handler. Middleware
+// registered on a ServiceObject runs inside the service-wide middleware.
+func (s Flex) Use(middleware ...Middleware) {
+	s.middleware.use(middleware...)
+}
+
+func (s Flex) processTask(task *Task) (*Task, *Task) {
+	if s.recorder == nil {
+		return s.dispatch(task)
+	}
+
+	received := s.recorder.capture(task)
+	taskErr, result := s.dispatch(task)
+	// shed tasks say nothing about how handlers behave
+	if result == nil || !result.retryable {
+		s.recorder.record(received, taskErr, result)
+	}
+
+	return taskErr, result
+}
+
+func (s Flex) dispatch(task *Task) (*Task, *Task) {
+	task.SDKVersion = flexGoVersion
+
+	if s.sharedSecret != "" && task.TaskType != "serviceDiscovery" && task.TaskType != "logger" && task.TaskType != "moduleGenerator" && s.sharedSecret != task.AuthKey {
+		return nil, task
+	}
+
+	if !util.Contains(flexTaskTypes, task.TaskType) {
+		return nil, task
+	}
+
+	if task.TaskType == "serviceDiscovery" {
+		so := dataLink{
+			ServiceObjects: s.Data.getServiceObjects(),
+			Metadata:       s.Data.discover(),
+		}
+		fh := businessLogic{
+			Handlers: s.Functions.getHandlers(),
+			Metadata: s.Functions.discover(),
+		}
+		ah := authDiscovery{
+			Handlers: s.Auth.getHandlers(),
+			Metadata: s.Auth.discover(),
+		}
+
+		dco := discoveryObjects{
+			DataLink:      so,
+			BusinessLogic: fh,
+			Auth:          ah,
+		}
+
+		task.DiscoveryObjects = dco
+
+		return nil, task
+	}
+
+	if limiter, ok := s.limiters[task.TaskType]; ok {
+		if !limiter.acquire() {
+			complete := NewKinveyCompletionHandler(task)
+			return complete.ServiceUnavailable("Too many concurrent " + task.TaskType + " tasks").Done()
+		}
+		defer limiter.release()
+	}
+
+	modules := generateModules(task, s.baas)
+
+	switch task.TaskType {
+	case "data":
+		return s.Data.process(task, modules)
+	case "functions":
+		return s.Functions.process(task, modules)
+	case "auth":
+		return s.Auth.process(task, modules)
+	}
+
+	return nil, nil
+}
+
 func terminate(err error) {
 	if err != nil {
 		fmt.Println(err.Error())
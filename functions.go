@@ -1,19 +1,36 @@
 package flex
 
+import (
+	"sync"
+)
+
 // Functions ...
 type Functions interface {
 	getHandlers() []string
+	discover() map[string]HandlerMetadata
+	hasHandler(taskName string) bool
 	clearAll()
 	resolve(taskName string) func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task)
 	process(task *Task, modules Modules) (*Task, *Task)
 	Register(taskName string, functionToExecute func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task))
+	SetSchema(taskName string, schema *Schema)
+	SetPolicy(taskName string, policy *Policy)
+	SetConcurrencyLimit(taskName string, limit ConcurrencyLimit)
+	SetRateLimit(taskName string, limit RateLimit)
+	Describe(taskName string, metadata HandlerMetadata)
 }
 
 type functions struct {
+	mu                  sync.RWMutex
 	registeredFunctions map[string]func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task)
+	options             map[string]handlerOptions
+	middleware          *middlewareStack
 }
 
 func (ff *functions) getHandlers() []string {
+	ff.mu.RLock()
+	defer ff.mu.RUnlock()
+
 	keys := make([]string, 0)
 	for key := range ff.registeredFunctions {
 		keys = append(keys, key)
@@ -21,25 +38,67 @@ func (ff *functions) getHandlers() []string {
 	return keys
 }
 
+func (ff *functions) discover() map[string]HandlerMetadata {
+	ff.mu.RLock()
+	defer ff.mu.RUnlock()
+
+	metadata := make(map[string]HandlerMetadata)
+	for taskName := range ff.registeredFunctions {
+		metadata[taskName] = ff.options[taskName].describe()
+	}
+	return metadata
+}
+
+func (ff *functions) hasHandler(taskName string) bool {
+	ff.mu.RLock()
+	defer ff.mu.RUnlock()
+
+	_, ok := ff.registeredFunctions[taskName]
+	return ok
+}
+
 func (ff *functions) resolve(taskName string) func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task) {
+	ff.mu.RLock()
+	defer ff.mu.RUnlock()
+
 	if i, ok := ff.registeredFunctions[taskName]; ok {
 		return i
 	}
 	return KinveyNotImplementedHandler()
 }
 
+func (ff *functions) handlerOptions(taskName string) handlerOptions {
+	ff.mu.RLock()
+	defer ff.mu.RUnlock()
+
+	return ff.options[taskName]
+}
+
+func (ff *functions) setOptions(taskName string, apply func(o *handlerOptions)) {
+	ff.mu.Lock()
+	o := ff.options[taskName]
+	apply(&o)
+	ff.options[taskName] = o
+	ff.mu.Unlock()
+}
+
 func (ff *functions) process(task *Task, modules Modules) (*Task, *Task) {
 	context := &Request{}
 	var currentContext netType
 
+	// handlers see the body being hooked: the response for post hooks, the
+	// request otherwise
 	if task.HookType == "post" {
 		currentContext = &task.Response
+		context.Body = task.Response.Body
 	} else {
 		currentContext = &task.Request
+		context.Body = task.Request.Body
 	}
 
 	context.Method = task.Request.Method
 	context.Headers = currentContext.GetHeaders()
+	context.Query = task.Request.Query
 	context.Username = task.Request.Username
 	context.UserID = task.Request.UserID
 
@@ -56,23 +115,67 @@ func (ff *functions) process(task *Task, modules Modules) (*Task, *Task) {
 	}
 
 	functionCompletionHandler := NewKinveyCompletionHandler(task)
-	functionHandler := ff.resolve(task.TaskName)
+	functionHandler := ff.middleware.wrap(ff.handlerOptions(task.TaskName).wrap(ff.resolve(task.TaskName)))
 
 	return functionHandler(context, functionCompletionHandler, modules)
 }
 
 func (ff *functions) clearAll() {
+	ff.mu.Lock()
 	ff.registeredFunctions = make(map[string]func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task))
+	ff.mu.Unlock()
 }
 
 // Register ...
 func (ff *functions) Register(taskName string, functionToExecute func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task)) {
+	ff.mu.Lock()
 	ff.registeredFunctions[taskName] = functionToExecute
+	ff.mu.Unlock()
+}
+
+// SetSchema validates request bodies for taskName against schema before the
+// handler runs. Pass a nil schema to stop validating.
+func (ff *functions) SetSchema(taskName string, schema *Schema) {
+	ff.setOptions(taskName, func(o *handlerOptions) {
+		o.schema = schema
+	})
+}
+
+// SetPolicy restricts which callers may run taskName. Pass a nil policy to
+// allow everyone.
+func (ff *functions) SetPolicy(taskName string, policy *Policy) {
+	ff.setOptions(taskName, func(o *handlerOptions) {
+		o.policy = policy
+	})
+}
+
+// SetConcurrencyLimit bounds how many taskName requests run at once.
+func (ff *functions) SetConcurrencyLimit(taskName string, limit ConcurrencyLimit) {
+	ff.setOptions(taskName, func(o *handlerOptions) {
+		o.limiter = newConcurrencyLimiter(limit)
+	})
+}
+
+// SetRateLimit limits how often each caller may run taskName.
+func (ff *functions) SetRateLimit(taskName string, limit RateLimit) {
+	ff.setOptions(taskName, func(o *handlerOptions) {
+		o.rate = newRateLimiter(limit)
+	})
+}
+
+// Describe sets the discovery metadata for taskName. Only the fields set in
+// metadata are changed.
+func (ff *functions) Describe(taskName string, metadata HandlerMetadata) {
+	ff.setOptions(taskName, func(o *handlerOptions) {
+		o.metadata = o.metadata.merge(metadata)
+	})
 }
 
-func newFunctions() Functions {
+func newFunctions(middleware *middlewareStack) Functions {
 	ff := &functions{
 		registeredFunctions: make(map[string]func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task)),
+		options:             make(map[string]handlerOptions),
+		middleware:          middleware,
 	}
 	return ff
 }
@@ -1,21 +1,19 @@
 package flex
 
-import (
-	"net/http"
-)
-
 // GroupStoreModule ...
 type GroupStoreModule struct {
 	appMetadata    kinveyAppMetadata
 	requestContext RequestMetadata
 	taskMetadata   TaskMetadata
+	baas           *baasClient
 }
 
-func newGroupStoreModule(appMetadata kinveyAppMetadata, requestMetadata RequestMetadata, taskMetadata TaskMetadata) GroupStoreModule {
+func newGroupStoreModule(appMetadata kinveyAppMetadata, requestMetadata RequestMetadata, taskMetadata TaskMetadata, baas *baasClient) GroupStoreModule {
 	return GroupStoreModule{
 		appMetadata:    appMetadata,
 		requestContext: requestMetadata,
 		taskMetadata:   taskMetadata,
+		baas:           baas,
 	}
 }
 
@@ -31,7 +29,7 @@ func (m GroupStoreModule) NewGroupStore(useUserContext bool) GroupStore {
 	s.useBL = true
 	s.useUserContext = useUserContext
 
-	s.client = &http.Client{}
+	s.baas = m.baas
 
 	return s
 }
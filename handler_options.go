@@ -0,0 +1,53 @@
+package flex
+
+// handlerOptions holds the settings attached to a single data operation or
+// function. They are applied in front of the resolved handler, inside any
+// middleware.
+type handlerOptions struct {
+	schema  *Schema
+	policy  *Policy
+	limiter *concurrencyLimiter
+	rate    *rateLimiter
+
+	metadata HandlerMetadata
+}
+
+func (o handlerOptions) wrap(handler HandlerFunc) HandlerFunc {
+	return o.wrapRequest(o.wrapEntity(handler))
+}
+
+// wrapEntity applies the options that check each entity, which array inserts
+// run once per entity.
+func (o handlerOptions) wrapEntity(handler HandlerFunc) HandlerFunc {
+	if o.schema != nil {
+		handler = validateBody(o.schema, handler)
+	}
+	return handler
+}
+
+// wrapRequest applies the options that admit the request, which array inserts
+// run once for the whole array.
+func (o handlerOptions) wrapRequest(handler HandlerFunc) HandlerFunc {
+	if o.limiter != nil {
+		handler = limitConcurrency(o.limiter, handler)
+	}
+	// callers are authorized before their request body is looked at, and
+	// unauthorized callers never take or wait for a concurrency slot
+	if o.policy != nil {
+		handler = enforcePolicy(o.policy, handler)
+	}
+	// rate limits apply to every caller, so rejected callers count too
+	if o.rate != nil {
+		handler = limitRate(o.rate, handler)
+	}
+	return handler
+}
+
+func validateBody(schema *Schema, next HandlerFunc) HandlerFunc {
+	return func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task) {
+		if violations := schema.Validate(context.Body); len(violations) > 0 {
+			return complete.ValidationError(violations).Done()
+		}
+		return next(context, complete, modules)
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	jsoniter "github.com/json-iterator/go"
 )
 
 type healthCheckResponse struct {
@@ -21,12 +22,26 @@ type healthCheckResponse struct {
 }
 
 type functionsResponse struct {
-	Request  *Request  `json:"request"`
-	Response *Response `json:"response"`
+	Request  functionsRequestBody  `json:"request"`
+	Response functionsResponseBody `json:"response"`
 }
 
+// functionsRequestBody and functionsResponseBody send the bodies as raw JSON,
+// so handlers may reply with arrays and scalars as well as objects.
+type functionsRequestBody struct {
+	*Request
+	Body jsoniter.RawMessage `json:"body"`
+}
+
+type functionsResponseBody struct {
+	*Response
+	Body jsoniter.RawMessage `json:"body"`
+}
+
+// locals is the body of a functions or auth request. Body is kept as raw JSON
+// so the handler receives the entity exactly as it was sent.
 type locals struct {
-	Body            []byte                 `json:"body"`
+	Body            jsoniter.RawMessage    `json:"body"`
 	HookType        string                 `json:"hookType"`
 	Method          string                 `json:"method"`
 	Query           string                 `json:"query"`
@@ -56,6 +71,19 @@ func (rec *httpReceiver) healthCheck() http.Handler {
 	})
 }
 
+func (rec *httpReceiver) openAPI(flex Flex) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		doc, err := flex.OpenAPI()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(doc)
+	})
+}
+
 func (rec *httpReceiver) mapPostToElements(ctx *context) error {
 	err := json.Unmarshal(ctx.Request.Body, &ctx.Locals)
 	if err != nil {
@@ -89,6 +117,9 @@ func (rec *httpReceiver) generateBaseTask(ctx *context) error {
 			requestHeaders["x-kinvey-api-version"] = k.KinveyAPIVersion
 			requestHeaders["authorization"] = k.Authorization
 			requestHeaders["x-kinvey-client-app-version"] = k.KinveyClientAppVersion
+			if k.ForwardedFor != "" {
+				requestHeaders["x-forwarded-for"] = k.ForwardedFor
+			}
 		}
 	}
 
@@ -186,7 +217,7 @@ func (rec *httpReceiver) appendCount(ctx *context) error {
 
 func (rec *httpReceiver) appendBody(ctx *context) error {
 	if ctx.Locals.Body != nil {
-		ctx.Task.Request.Body = ctx.Locals.Body
+		ctx.Task.Request.Body = []byte(ctx.Locals.Body)
 	} else {
 		ctx.Task.Request.Body = ctx.Request.Body
 	}
@@ -199,14 +230,16 @@ func (rec *httpReceiver) getDataBody(task *Task) string {
 
 func (rec *httpReceiver) getFunctionsBody(task *Task) string {
 	fr := functionsResponse{
-		Request:  &task.Request,
-		Response: &task.Response,
+		Request: functionsRequestBody{
+			Request: &task.Request,
+			Body:    encodeBody(task.Request.Body, task.Request.JSONBody),
+		},
+		Response: functionsResponseBody{
+			Response: &task.Response,
+			Body:     encodeBody(task.Response.Body, task.Response.JSONBody),
+		},
 	}
 
-	json.Unmarshal(fr.Request.Body, &fr.Request.JSONBody)
-
-	json.Unmarshal(fr.Response.Body, &fr.Response.JSONBody)
-
 	json, _ := json.Marshal(fr)
 
 	return string(json)
@@ -253,6 +286,16 @@ func (rec *httpReceiver) sendTask(task *Task, taskReceivedCallback func(task *Ta
 			w.Header().Set("Connection", "close")
 			w.Header().Set("Content-Type", "application/json")
 
+			if result.retryable {
+				w.Header().Set("Retry-After", "1")
+			}
+
+			// data and auth replies carry the handler's status, defaulting to
+			// 200; functions report their status inside the body
+			if result.Response.Status != 0 && (task.TaskType == "data" || task.TaskType == "auth") {
+				w.WriteHeader(result.Response.Status)
+			}
+
 			w.Write([]byte(body))
 		}
 
@@ -292,47 +335,74 @@ func (rec *httpReceiver) dataGroupHandler(taskReceivedCallback func(task *Task)
 	})
 }
 
-// Start ...
-func (rec *httpReceiver) Start(flex Flex, taskReceivedCallback func(task *Task) (*Task, *Task), options string) error {
-	router := gin.New()
+// requireHandler resolves the task name from the request path against the live
+// registry, so handlers registered or removed after startup are routed correctly.
+func (rec *httpReceiver) requireHandler(hasHandler func(taskName string) bool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !hasHandler(path.Base(r.URL.Path)) {
+			http.NotFound(w, r)
+			return
+		}
 
-	router.POST("/healthcheck", gin.WrapH(rec.healthCheck()))
+		next.ServeHTTP(w, r)
+	})
+}
 
-	// FlexFunctions
-	ff := router.Group("/_flexFunctions/")
-	{
-		for _, h := range flex.Functions.getHandlers() {
-			ff.POST(h, gin.WrapH(newChain(rec.mapPostToElements, rec.generateBaseTask, rec.addFunctionsTaskAttributes, rec.appendQuery, rec.appendID, rec.appendBody).then(rec.sendTask, taskReceivedCallback)))
+func (rec *httpReceiver) requireServiceObject(hasServiceObject func(name string) bool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !hasServiceObject(strings.Split(r.URL.Path, "/")[1]) {
+			http.NotFound(w, r)
+			return
 		}
-	}
 
-	// FlexAuth
-	fa := router.Group("/_auth/")
-	{
-		for _, h := range flex.Auth.getHandlers() {
-			fa.POST(h, gin.WrapH(newChain(rec.mapPostToElements, rec.generateBaseTask, rec.addFunctionsTaskAttributes, rec.appendQuery, rec.appendID, rec.appendBody).then(rec.sendTask, taskReceivedCallback)))
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (rec *httpReceiver) registerRoutes(group *gin.RouterGroup, flex Flex, taskReceivedCallback func(task *Task) (*Task, *Task)) {
+	prefix := strings.TrimSuffix(group.BasePath(), "/")
+
+	wrap := func(h http.Handler) gin.HandlerFunc {
+		if prefix != "" {
+			h = http.StripPrefix(prefix, h)
 		}
+		return gin.WrapH(h)
 	}
 
+	group.POST("/healthcheck", wrap(rec.healthCheck()))
+
+	// FlexFunctions
+	group.POST("/_flexFunctions/:taskName", wrap(rec.requireHandler(flex.Functions.hasHandler, newChain(rec.mapPostToElements, rec.generateBaseTask, rec.addFunctionsTaskAttributes, rec.appendQuery, rec.appendID, rec.appendBody).then(rec.sendTask, taskReceivedCallback))))
+
+	// FlexAuth
+	group.POST("/_auth/:taskName", wrap(rec.requireHandler(flex.Auth.hasHandler, newChain(rec.mapPostToElements, rec.generateBaseTask, rec.addAuthTaskAttributes, rec.appendQuery, rec.appendID, rec.appendBody).then(rec.sendTask, taskReceivedCallback))))
+
 	// Command
-	router.POST("/_command/discover", gin.WrapH(newChain(rec.buildDiscoverTask).then(rec.sendTask, taskReceivedCallback)))
+	group.POST("/_command/discover", wrap(newChain(rec.buildDiscoverTask).then(rec.sendTask, taskReceivedCallback)))
+	group.POST("/_command/openapi", wrap(rec.openAPI(flex)))
+	group.GET("/_command/openapi", wrap(rec.openAPI(flex)))
 
 	// FlexData
-	for _, so := range flex.Data.getServiceObjects() {
-		g := router.Group("/" + so)
+	g := group.Group("/:serviceObject")
+	{
+		g.POST("", wrap(rec.requireServiceObject(flex.Data.hasServiceObject, newChain(rec.generateBaseTask, rec.addDataTaskAttributes, rec.appendBody).then(rec.sendTask, taskReceivedCallback))))
+		g.DELETE("", wrap(rec.requireServiceObject(flex.Data.hasServiceObject, newChain(rec.generateBaseTask, rec.addDataTaskAttributes, rec.appendQuery).then(rec.sendTask, taskReceivedCallback))))
+		g.GET("", wrap(rec.requireServiceObject(flex.Data.hasServiceObject, rec.dataGroupHandler(taskReceivedCallback))))
+
+		j := g.Group("/:param")
 		{
-			g.POST("", gin.WrapH(newChain(rec.generateBaseTask, rec.addDataTaskAttributes, rec.appendBody).then(rec.sendTask, taskReceivedCallback)))
-			g.DELETE("", gin.WrapH(newChain(rec.generateBaseTask, rec.addDataTaskAttributes, rec.appendQuery).then(rec.sendTask, taskReceivedCallback)))
-			g.GET("", gin.WrapH(rec.dataGroupHandler(taskReceivedCallback)))
-
-			j := g.Group("/:param")
-			{
-				j.GET("", gin.WrapH(rec.dataGroupHandler(taskReceivedCallback)))
-				j.PUT("", gin.WrapH(newChain(rec.generateBaseTask, rec.addDataTaskAttributes, rec.appendID, rec.appendBody).then(rec.sendTask, taskReceivedCallback)))
-				j.DELETE("", gin.WrapH(newChain(rec.generateBaseTask, rec.addDataTaskAttributes, rec.appendID, rec.appendQuery).then(rec.sendTask, taskReceivedCallback)))
-			}
+			j.GET("", wrap(rec.requireServiceObject(flex.Data.hasServiceObject, rec.dataGroupHandler(taskReceivedCallback))))
+			j.PUT("", wrap(rec.requireServiceObject(flex.Data.hasServiceObject, newChain(rec.generateBaseTask, rec.addDataTaskAttributes, rec.appendID, rec.appendBody).then(rec.sendTask, taskReceivedCallback))))
+			j.PATCH("", wrap(rec.requireServiceObject(flex.Data.hasServiceObject, newChain(rec.generateBaseTask, rec.addDataTaskAttributes, rec.appendID, rec.appendBody).then(rec.sendTask, taskReceivedCallback))))
+			j.DELETE("", wrap(rec.requireServiceObject(flex.Data.hasServiceObject, newChain(rec.generateBaseTask, rec.addDataTaskAttributes, rec.appendID, rec.appendQuery).then(rec.sendTask, taskReceivedCallback))))
 		}
 	}
+}
+
+func (rec *httpReceiver) handler(flex Flex, taskReceivedCallback func(task *Task) (*Task, *Task)) http.Handler {
+	router := gin.New()
+
+	rec.registerRoutes(&router.RouterGroup, flex, taskReceivedCallback)
 
 	/*d := router.Group("/debug/pprof/")
 	{
@@ -343,10 +413,15 @@ func (rec *httpReceiver) Start(flex Flex, taskReceivedCallback func(task *Task)
 		d.GET("trace", gin.WrapH(http.HandlerFunc(pprof.Trace)))
 	}*/
 
+	return router
+}
+
+// Start ...
+func (rec *httpReceiver) Start(flex Flex, taskReceivedCallback func(task *Task) (*Task, *Task), options string) error {
 	address := ":10001"
 	rec.server = &http.Server{
 		Addr:    address,
-		Handler: router,
+		Handler: rec.handler(flex, taskReceivedCallback),
 		//ReadTimeout:  5 * time.Second,
 		//WriteTimeout: 10 * time.Second,
 	}
@@ -370,6 +445,33 @@ func (rec *httpReceiver) Stop() error {
 	return nil
 }
 
+// NewHandler initializes a Flex service like NewService, but instead of starting
+// a receiver it returns the Flex routes as an http.Handler. Mount it at a prefix
+// with http.StripPrefix to serve Flex alongside an existing server.
+func NewHandler(options *Options, initializer func(err error, flex Flex)) http.Handler {
+	s := newFlex(options)
+
+	initializer(nil, s)
+
+	rec := &httpReceiver{}
+	return rec.handler(s, s.processTask)
+}
+
+// RegisterRoutes initializes a Flex service like NewService and registers its
+// routes on an existing gin RouterGroup. The group's base path is stripped before
+// tasks are built, so the routes can live under any prefix.
+func RegisterRoutes(group *gin.RouterGroup, options *Options, initializer func(err error, flex Flex)) {
+	s := newFlex(options)
+	if len(s.openAPI.Servers) == 0 && group.BasePath() != "/" {
+		s.openAPI.Servers = []string{group.BasePath()}
+	}
+
+	initializer(nil, s)
+
+	rec := &httpReceiver{}
+	rec.registerRoutes(group, s, s.processTask)
+}
+
 type context struct {
 	Response Response
 	Request  request
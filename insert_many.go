@@ -0,0 +1,112 @@
+package flex
+
+import (
+	"bytes"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+type multiInsertError struct {
+	Index        int    `json:"index"`
+	Code         string `json:"code"`
+	ErrorMessage string `json:"errorMessage"`
+}
+
+type multiInsertResponse struct {
+	Entities []jsoniter.RawMessage `json:"entities"`
+	Errors   []multiInsertError    `json:"errors"`
+}
+
+func isJSONArray(body []byte) bool {
+	body = bytes.TrimSpace(body)
+	return len(body) > 0 && body[0] == '['
+}
+
+// insertEach runs the onInsert handler once per entity of an array body and
+// combines the results into Kinvey's multi-insert response. Entities that
+// fail are reported by index in errors and left null in entities. Middleware,
+// policies and rate and concurrency limits admit the array as one request;
+// only schemas and the cache are applied per entity.
+func (fd *data) insertEach(so *serviceObject, task *Task, modules Modules) (*Task, *Task) {
+	complete := NewKinveyCompletionHandler(task)
+
+	entities := make([]jsoniter.RawMessage, 0)
+	err := json.Unmarshal(task.Request.Body, &entities)
+	if err != nil {
+		return complete.BadRequest(err.Error()).Done()
+	}
+
+	// without onInsert, the fallback handler would report every entity as
+	// inserted
+	if !so.hasHandler("onInsert") {
+		return complete.notImplemented("onInsert is not implemented for " + so.name).Done()
+	}
+
+	entityHandler := so.entityHandler("onInsert")
+	insertAll := func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task) {
+		mir := multiInsertResponse{
+			Entities: make([]jsoniter.RawMessage, len(entities)),
+			Errors:   make([]multiInsertError, 0),
+		}
+
+		for i, entity := range entities {
+			entityTask := *complete.Task
+			entityTask.Request.Body = []byte(entity)
+			entityTask.Response = Response{}
+
+			taskErr, result := entityHandler(&entityTask.Request, NewKinveyCompletionHandler(&entityTask), modules)
+			if taskErr != nil {
+				mir.Entities[i] = jsoniter.RawMessage("null")
+				mir.Errors = append(mir.Errors, multiInsertError{
+					Index:        i,
+					Code:         "InsertFailed",
+					ErrorMessage: string(taskErr.Response.Body),
+				})
+				continue
+			}
+			if result == nil {
+				result = &entityTask
+			}
+
+			if result.Response.Status >= 400 {
+				ke := KinveyError{}
+				json.Unmarshal(result.Response.Body, &ke)
+				if ke.Error == "" {
+					ke.Error = "InsertFailed"
+				}
+				if ke.Debug != "" {
+					ke.Description = ke.Debug
+				}
+
+				mir.Entities[i] = jsoniter.RawMessage("null")
+				mir.Errors = append(mir.Errors, multiInsertError{
+					Index:        i,
+					Code:         ke.Error,
+					ErrorMessage: ke.Description,
+				})
+				continue
+			}
+
+			if len(result.Response.Body) == 0 {
+				mir.Entities[i] = jsoniter.RawMessage("null")
+			} else {
+				mir.Entities[i] = jsoniter.RawMessage(result.Response.Body)
+			}
+		}
+
+		body, err := json.Marshal(mir)
+		if err != nil {
+			return complete.RuntimeError(err.Error()).Done()
+		}
+
+		complete.SetBody(body)
+		if len(mir.Errors) > 0 {
+			complete.Task.Response.Status = 207
+			return complete.Done()
+		}
+		return complete.Created().Done()
+	}
+
+	handler := fd.middleware.wrap(so.requestHandler("onInsert", insertAll))
+	return handler(&task.Request, complete, modules)
+}
@@ -0,0 +1,98 @@
+package flex
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func insertManyTask(body string) *Task {
+	return &Task{
+		Method: "POST",
+		Request: Request{
+			ServiceObjectName: "widgets",
+			Body:              []byte(body),
+		},
+	}
+}
+
+func TestInsertManyWithoutOnInsert(t *testing.T) {
+	fd := newData(newMiddlewareStack())
+	fd.NewServiceObject("widgets")
+
+	task := insertManyTask(`[{"name":"a"},{"name":"b"}]`)
+	_, result := fd.process(task, generateModules(task, newBaaSClient(NewOptions("", 10001, ""))))
+
+	if result.Response.Status != 501 {
+		t.Fatalf("expected a 501, got %d %s", result.Response.Status, result.Response.Body)
+	}
+}
+
+func TestInsertManyReportsFailedEntities(t *testing.T) {
+	fd := newData(newMiddlewareStack())
+	widgets := fd.NewServiceObject("widgets")
+	widgets.OnInsert(func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task) {
+		switch string(context.Body) {
+		case `"invalid"`:
+			return complete.BadRequest("not a widget").Done()
+		case `"broken"`:
+			complete.SetBody([]byte("handler failed"))
+			return complete.Task, nil
+		}
+		return complete.SetBody(context.Body).Created().Done()
+	})
+
+	task := insertManyTask(`[{"name":"a"},"invalid","broken"]`)
+	_, result := fd.process(task, generateModules(task, newBaaSClient(NewOptions("", 10001, ""))))
+
+	if result.Response.Status != 207 {
+		t.Fatalf("expected a 207, got %d %s", result.Response.Status, result.Response.Body)
+	}
+
+	want := `{"entities":[{"name":"a"},null,null],"errors":[` +
+		`{"index":1,"code":"BadRequest","errorMessage":"not a widget"},` +
+		`{"index":2,"code":"InsertFailed","errorMessage":"handler failed"}]}`
+	if string(result.Response.Body) != want {
+		t.Fatalf("unexpected body %s", result.Response.Body)
+	}
+}
+
+func TestInsertManySucceeds(t *testing.T) {
+	fd := newData(newMiddlewareStack())
+	widgets := fd.NewServiceObject("widgets")
+	widgets.OnInsert(func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task) {
+		return complete.SetBody(context.Body).Done()
+	})
+
+	task := insertManyTask(`[{"name":"a"},{"name":"b"}]`)
+	_, result := fd.process(task, generateModules(task, newBaaSClient(NewOptions("", 10001, ""))))
+
+	if result.Response.Status != 201 || string(result.Response.Body) != `{"entities":[{"name":"a"},{"name":"b"}],"errors":[]}` {
+		t.Fatalf("unexpected response %d %s", result.Response.Status, result.Response.Body)
+	}
+}
+
+func TestInsertManyIsAdmittedOnce(t *testing.T) {
+	fd := newData(newMiddlewareStack())
+	widgets := fd.NewServiceObject("widgets")
+	widgets.OnInsert(func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task) {
+		return complete.SetBody(context.Body).Created().Done()
+	})
+	widgets.SetSchema("onInsert", &Schema{Type: "object", Required: []string{"name"}})
+	widgets.SetRateLimit("onInsert", RateLimit{Requests: 1, Per: time.Minute})
+	widgets.SetConcurrencyLimit("onInsert", ConcurrencyLimit{MaxConcurrent: 1, MaxQueued: -1})
+
+	// the array takes one rate limit token and one concurrency slot, and
+	// each entity is still checked against the schema
+	task := insertManyTask(`[{"name":"a"},{},{"name":"c"}]`)
+	_, result := fd.process(task, generateModules(task, newBaaSClient(NewOptions("", 10001, ""))))
+	if result.Response.Status != 207 || !strings.Contains(string(result.Response.Body), `"entities":[{"name":"a"},null,{"name":"c"}]`) {
+		t.Fatalf("expected only the entity without a name to fail, got %d %s", result.Response.Status, result.Response.Body)
+	}
+
+	task = insertManyTask(`[{"name":"d"},{"name":"e"}]`)
+	_, result = fd.process(task, generateModules(task, newBaaSClient(NewOptions("", 10001, ""))))
+	if result.Response.Status != 429 {
+		t.Fatalf("expected the next array to be rate limited as a whole, got %d %s", result.Response.Status, result.Response.Body)
+	}
+}
@@ -20,6 +20,115 @@ func (a *KinveyCompletionHandler) SetBody(body []byte) *KinveyCompletionHandler
 	return a
 }
 
+// OK ...
+func (a *KinveyCompletionHandler) OK() *KinveyCompletionHandler {
+	a.Task.Response.Status = 200
+	return a
+}
+
+// Created ...
+func (a *KinveyCompletionHandler) Created() *KinveyCompletionHandler {
+	a.Task.Response.Status = 201
+	return a
+}
+
+// BadRequest ...
+func (a *KinveyCompletionHandler) BadRequest(debug string) *KinveyCompletionHandler {
+	return a.setError(KinveyError{
+		Error:       "BadRequest",
+		Description: "Unable to understand request",
+		Debug:       debug,
+		StatusCode:  400,
+	})
+}
+
+// InsufficientCredentials ...
+func (a *KinveyCompletionHandler) InsufficientCredentials(debug string) *KinveyCompletionHandler {
+	return a.setError(KinveyError{
+		Error:       "InsufficientCredentials",
+		Description: "The credentials used to authenticate this request are not authorized to run this operation. Please retry your request with appropriate credentials",
+		Debug:       debug,
+		StatusCode:  401,
+	})
+}
+
+// Forbidden ...
+func (a *KinveyCompletionHandler) Forbidden(debug string) *KinveyCompletionHandler {
+	return a.setError(KinveyError{
+		Error:       "Forbidden",
+		Description: "The caller is not allowed to run this operation",
+		Debug:       debug,
+		StatusCode:  403,
+	})
+}
+
+// TooManyRequests ...
+func (a *KinveyCompletionHandler) TooManyRequests(debug string) *KinveyCompletionHandler {
+	return a.setError(KinveyError{
+		Error:       "TooManyRequests",
+		Description: "Too many requests have been made. Please retry your request later",
+		Debug:       debug,
+		StatusCode:  429,
+	})
+}
+
+// ServiceUnavailable replies with a 503 that receivers report as retryable.
+func (a *KinveyCompletionHandler) ServiceUnavailable(debug string) *KinveyCompletionHandler {
+	a.Task.retryable = true
+	return a.setError(KinveyError{
+		Error:       "ServiceUnavailable",
+		Description: "The Flex Service is too busy to handle the request. Please retry your request",
+		Debug:       debug,
+		StatusCode:  503,
+	})
+}
+
+// notImplemented replies with a 501. NotImplemented leaves the response as
+// the handler set it, so KinveyNotImplementedHandler can supply its own body.
+func (a *KinveyCompletionHandler) notImplemented(debug string) *KinveyCompletionHandler {
+	return a.setError(KinveyError{
+		Error:       "NotImplemented",
+		Description: "The request invoked a method that is not implemented",
+		Debug:       debug,
+		StatusCode:  501,
+	})
+}
+
+// RuntimeError ...
+func (a *KinveyCompletionHandler) RuntimeError(debug string) *KinveyCompletionHandler {
+	return a.setError(KinveyError{
+		Error:       "FlexRuntimeError",
+		Description: "The Flex Service had a runtime error. See debug message for details",
+		Debug:       debug,
+		StatusCode:  550,
+	})
+}
+
+// ValidationError ...
+func (a *KinveyCompletionHandler) ValidationError(violations []string) *KinveyCompletionHandler {
+	ve := newValidationError(violations)
+	body, _ := json.Marshal(ve)
+
+	a.Task.Response.Status = ve.StatusCode
+	a.Task.Response.Body = body
+	return a
+}
+
+// setError replies with a Kinvey error, or with the equivalent auth error
+// when middleware or a handler option stops an auth task.
+func (a *KinveyCompletionHandler) setError(kinveyError KinveyError) *KinveyCompletionHandler {
+	if a.Task.TaskType == "auth" {
+		setAuthError(a.Task, kinveyError.StatusCode, authErrorCode(kinveyError.StatusCode), kinveyError.Description, kinveyError.Debug)
+		return a
+	}
+
+	body, _ := json.Marshal(kinveyError)
+
+	a.Task.Response.Status = kinveyError.StatusCode
+	a.Task.Response.Body = body
+	return a
+}
+
 // NotImplemented ...
 func (a *KinveyCompletionHandler) NotImplemented() *KinveyCompletionHandler {
 	return a
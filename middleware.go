@@ -0,0 +1,41 @@
+package flex
+
+import (
+	"sync"
+)
+
+// HandlerFunc ...
+type HandlerFunc func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task)
+
+// Middleware wraps a resolved handler. It can inspect or modify the request
+// before calling next, modify complete.Task.Response afterwards, or skip next
+// entirely and finish the task itself with complete.Done().
+type Middleware func(next HandlerFunc) HandlerFunc
+
+type middlewareStack struct {
+	mu         sync.RWMutex
+	middleware []Middleware
+}
+
+func newMiddlewareStack() *middlewareStack {
+	return &middlewareStack{
+		middleware: make([]Middleware, 0),
+	}
+}
+
+func (ms *middlewareStack) use(middleware ...Middleware) {
+	ms.mu.Lock()
+	ms.middleware = append(ms.middleware, middleware...)
+	ms.mu.Unlock()
+}
+
+// wrap applies the stack to handler. Middleware registered first runs first.
+func (ms *middlewareStack) wrap(handler HandlerFunc) HandlerFunc {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	for i := len(ms.middleware) - 1; i >= 0; i-- {
+		handler = ms.middleware[i](handler)
+	}
+	return handler
+}
@@ -0,0 +1,92 @@
+package flex
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMiddlewareOrder(t *testing.T) {
+	s := newFlex(NewOptions("", 10001, ""))
+
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task) {
+				calls = append(calls, name)
+				taskErr, result := next(context, complete, modules)
+				calls = append(calls, name+" done")
+				return taskErr, result
+			}
+		}
+	}
+
+	s.Use(trace("first"), trace("second"))
+	widgets := s.Data.NewServiceObject("widgets")
+	widgets.Use(trace("widgets"))
+	widgets.OnGetAll(func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task) {
+		calls = append(calls, "handler")
+		return complete.OK().Done()
+	})
+
+	s.processTask(&Task{TaskType: "data", Method: "GET", Request: Request{ServiceObjectName: "widgets"}})
+
+	want := []string{"first", "second", "widgets", "handler", "widgets done", "second done", "first done"}
+	if len(calls) != len(want) {
+		t.Fatalf("expected %v, got %v", want, calls)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, calls)
+		}
+	}
+}
+
+func TestMiddlewareShortCircuits(t *testing.T) {
+	s := newFlex(NewOptions("", 10001, ""))
+	s.Use(RequireSecurityContext(SecurityContextMaster))
+
+	called := false
+	s.Data.NewServiceObject("widgets").OnGetAll(func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task) {
+		called = true
+		return complete.OK().Done()
+	})
+	s.Auth.Register("login", func(context *Request, complete AuthCompletionHandler, modules Modules) (*Task, *Task) {
+		called = true
+		return complete.SetToken("token").OK().Done()
+	})
+
+	_, result := s.processTask(&Task{TaskType: "data", Method: "GET", Request: Request{ServiceObjectName: "widgets"}})
+	if called || result.Response.Status != 401 {
+		t.Fatalf("expected a 401 without running the handler, got %d", result.Response.Status)
+	}
+	kinveyError := KinveyError{}
+	if err := json.Unmarshal(result.Response.Body, &kinveyError); err != nil || kinveyError.Error != "InsufficientCredentials" {
+		t.Fatalf("expected a Kinvey error for a data task, got %s", result.Response.Body)
+	}
+
+	// auth tasks are answered in the auth error format
+	_, result = s.processTask(&Task{TaskType: "auth", TaskName: "login"})
+	if called || result.Response.Status != 401 {
+		t.Fatalf("expected a 401 without running the handler, got %d", result.Response.Status)
+	}
+	authErr := authError{}
+	if err := json.Unmarshal(result.Response.Body, &authErr); err != nil || authErr.Error != "access_denied" || authErr.ErrorDescription == "" {
+		t.Fatalf("expected an auth error for an auth task, got %s", result.Response.Body)
+	}
+}
+
+func TestAuthRateLimitUsesAuthErrors(t *testing.T) {
+	s := newFlex(NewOptions("", 10001, ""))
+	s.Auth.Register("login", func(context *Request, complete AuthCompletionHandler, modules Modules) (*Task, *Task) {
+		return complete.SetToken("token").OK().Done()
+	})
+	s.Auth.SetRateLimit("login", RateLimit{Requests: 1, Per: time.Minute})
+
+	s.processTask(&Task{TaskType: "auth", TaskName: "login"})
+	_, result := s.processTask(&Task{TaskType: "auth", TaskName: "login"})
+
+	authErr := authError{}
+	if err := json.Unmarshal(result.Response.Body, &authErr); err != nil || result.Response.Status != 429 || authErr.Error != "temporarily_unavailable" {
+		t.Fatalf("expected a 429 auth error, got %d %s", result.Response.Status, result.Response.Body)
+	}
+}
@@ -1,56 +1,194 @@
 package flex
 
 import (
-	"encoding/base64"
 	"strings"
+	"sync"
 )
 
-// Modules ...
+// Modules gives handlers access to the Kinvey backend. Each module is built
+// the first time it is accessed, so a task only pays for the modules its
+// handler actually uses. A zero Modules, as in handler tests, gives modules
+// for an empty task, built afresh on every access.
 type Modules struct {
-	BackendContext  BackendContextModule
-	DataStore       DataStoreModule
-	Email           EmailModule
-	EndpointRunner  EndpointRunnerModule
-	KinveyEntity    KinveyEntityModule
-	RoleStore       RoleStoreModule
-	TempObjectStore TempObjectStoreModule
-	UserStore       UserStoreModule
-	GroupStore      GroupStoreModule
-	Logger          Logger
+	lazy *lazyModules
+}
+
+type lazyModules struct {
+	task *Task
+	baas *baasClient
+
+	metadata        lazy[moduleMetadata]
+	backendContext  lazy[BackendContextModule]
+	dataStore       lazy[DataStoreModule]
+	email           lazy[EmailModule]
+	endpointRunner  lazy[EndpointRunnerModule]
+	kinveyEntity    lazy[KinveyEntityModule]
+	requestContext  lazy[RequestContextModule]
+	roleStore       lazy[RoleStoreModule]
+	tempObjectStore lazy[TempObjectStoreModule]
+	userStore       lazy[UserStoreModule]
+	groupStore      lazy[GroupStoreModule]
+	logger          lazy[Logger]
+
+	// roleMembers caches the policy role lookups of the task, by role ID
+	roleMembersMu sync.Mutex
+	roleMembers   map[string]bool
 	//kinveyDate,
 	//push: push(appMetadata),
 	//Query,
-	//requestContext: requestContext(requestMetadata),
 }
 
-func getSecurityContextString(authorizationHeader string, appMetadata kinveyAppMetadata) string {
-	encodedCredentials := strings.Split(authorizationHeader, " ")
+type lazy[T any] struct {
+	once  sync.Once
+	value T
+}
 
-	if len(encodedCredentials) == 2 {
-		decodedCredentials, err := base64.StdEncoding.DecodeString(encodedCredentials[1])
-		if err != nil {
-			return "unknown"
-		}
+func (l *lazy[T]) get(build func() T) T {
+	l.once.Do(func() {
+		l.value = build()
+	})
+	return l.value
+}
 
-		credentials := strings.Split(string(decodedCredentials), ":")
+type moduleMetadata struct {
+	appMetadata     kinveyAppMetadata
+	requestMetadata RequestMetadata
+	taskMetadata    TaskMetadata
+	useBSONObjectID bool
+}
 
-		if len(credentials) == 2 {
-			if credentials[1] != appMetadata.ID {
-				return "user"
-			}
-			if credentials[1] == appMetadata.ID && credentials[2] != "" {
-				return "app"
-			}
-			if credentials[2] == appMetadata.MasterSecret {
-				return "master"
-			}
-		}
+var (
+	defaultBaaSOnce sync.Once
+	defaultBaaS     *baasClient
+)
+
+// modules returns the task's modules, or modules for an empty task when m is
+// the zero value.
+func (m Modules) modules() *lazyModules {
+	if m.lazy != nil {
+		return m.lazy
+	}
+
+	defaultBaaSOnce.Do(func() {
+		defaultBaaS = newBaaSClient(NewOptions("", 0, ""))
+	})
+	return &lazyModules{task: &Task{}, baas: defaultBaaS}
+}
+
+func (l *lazyModules) moduleMetadata() moduleMetadata {
+	return l.metadata.get(func() moduleMetadata {
+		return buildModuleMetadata(l.task)
+	})
+}
+
+// BackendContext ...
+func (m Modules) BackendContext() BackendContextModule {
+	l := m.modules()
+	return l.backendContext.get(func() BackendContextModule {
+		return newBackendContextModule(l.moduleMetadata().appMetadata)
+	})
+}
+
+// DataStore ...
+func (m Modules) DataStore() DataStoreModule {
+	l := m.modules()
+	return l.dataStore.get(func() DataStoreModule {
+		md := l.moduleMetadata()
+		return newDataStoreModule(md.appMetadata, md.requestMetadata, md.taskMetadata, l.baas)
+	})
+}
+
+// Email ...
+func (m Modules) Email() EmailModule {
+	l := m.modules()
+	return l.email.get(func() EmailModule {
+		return newEmailModule(l.moduleMetadata().appMetadata, l.baas)
+	})
+}
+
+// EndpointRunner ...
+func (m Modules) EndpointRunner() EndpointRunnerModule {
+	l := m.modules()
+	return l.endpointRunner.get(func() EndpointRunnerModule {
+		md := l.moduleMetadata()
+		return newEndpointRunnerModule(md.appMetadata, md.requestMetadata, md.taskMetadata, l.baas)
+	})
+}
+
+// KinveyEntity ...
+func (m Modules) KinveyEntity() KinveyEntityModule {
+	l := m.modules()
+	return l.kinveyEntity.get(func() KinveyEntityModule {
+		md := l.moduleMetadata()
+		return newKinveyEntityModule(md.appMetadata.ID, md.useBSONObjectID)
+	})
+}
+
+// RequestContext ...
+func (m Modules) RequestContext() RequestContextModule {
+	l := m.modules()
+	return l.requestContext.get(func() RequestContextModule {
+		return newRequestContextModule(l.moduleMetadata().requestMetadata)
+	})
+}
+
+// RoleStore ...
+func (m Modules) RoleStore() RoleStoreModule {
+	l := m.modules()
+	return l.roleStore.get(func() RoleStoreModule {
+		md := l.moduleMetadata()
+		return newRoleStoreModule(md.appMetadata, md.requestMetadata, md.taskMetadata, l.baas)
+	})
+}
+
+// TempObjectStore ...
+func (m Modules) TempObjectStore() TempObjectStoreModule {
+	l := m.modules()
+	return l.tempObjectStore.get(newTempObjectStoreModule)
+}
+
+// UserStore ...
+func (m Modules) UserStore() UserStoreModule {
+	l := m.modules()
+	return l.userStore.get(func() UserStoreModule {
+		md := l.moduleMetadata()
+		return newUserStoreModule(md.appMetadata, md.requestMetadata, md.taskMetadata, l.baas)
+	})
+}
+
+// GroupStore ...
+func (m Modules) GroupStore() GroupStoreModule {
+	l := m.modules()
+	return l.groupStore.get(func() GroupStoreModule {
+		md := l.moduleMetadata()
+		return newGroupStoreModule(md.appMetadata, md.requestMetadata, md.taskMetadata, l.baas)
+	})
+}
+
+// Logger ...
+func (m Modules) Logger() Logger {
+	l := m.modules()
+	return l.logger.get(newLogger)
+}
+
+func generateModules(task *Task, baas *baasClient) Modules {
+	if task.Request.ServiceObjectName != "" {
+		task.ObjectName = task.Request.ServiceObjectName
+	} else if task.Request.ObjectName != "" {
+		task.ObjectName = task.Request.ObjectName
+	} else {
+		task.ObjectName = task.Request.CollectionName
 	}
 
-	return "unknown"
+	return Modules{
+		lazy: &lazyModules{
+			task: task,
+			baas: baas,
+		},
+	}
 }
 
-func generateModules(task *Task) Modules {
+func buildModuleMetadata(task *Task) moduleMetadata {
 	var clientAppVersion string
 	var customRequestProperties map[string]interface{}
 
@@ -97,6 +235,7 @@ func generateModules(task *Task) Modules {
 		AuthenticatedUserID:     task.Request.UserID,
 		Authorization:           task.Request.Headers["authorization"],
 		ClientAppVersion:        clientAppVersion,
+		ClientIP:                clientIP(task.Request.Headers["x-forwarded-for"], 1),
 		CustomRequestProperties: customRequestProperties,
 		RequestID:               task.RequestID,
 	}
@@ -115,28 +254,13 @@ func generateModules(task *Task) Modules {
 		ContainerID: task.ContainerID,
 	}
 
-	if task.Request.ServiceObjectName != "" {
-		task.ObjectName = task.Request.ServiceObjectName
-	} else if task.Request.ObjectName != "" {
-		task.ObjectName = task.Request.ObjectName
-	} else {
-		task.ObjectName = task.Request.CollectionName
-	}
+	requestMetadata.CredentialType = taskCredentialType(task)
+	requestMetadata.SecurityContext = securityContextFor(requestMetadata.CredentialType)
 
-	requestMetadata.SecurityContext = getSecurityContextString(task.Request.Headers["authorization"], appMetadata)
-
-	useBSONObjectID := task.AppMetadata.Maintenance.ObjectIDMigration.Status != "done"
-
-	return Modules{
-		DataStore:       newDataStoreModule(appMetadata, requestMetadata, taskMetadata),
-		EndpointRunner:  newEndpointRunnerModule(appMetadata, requestMetadata, taskMetadata),
-		TempObjectStore: newTempObjectStoreModule(),
-		KinveyEntity:    newKinveyEntityModule(appMetadata.ID, useBSONObjectID),
-		BackendContext:  newBackendContextModule(appMetadata),
-		RoleStore:       newRoleStoreModule(appMetadata, requestMetadata, taskMetadata),
-		Email:           newEmailModule(appMetadata),
-		UserStore:       newUserStoreModule(appMetadata, requestMetadata, taskMetadata),
-		GroupStore:      newGroupStoreModule(appMetadata, requestMetadata, taskMetadata),
-		Logger:          newLogger(),
+	return moduleMetadata{
+		appMetadata:     appMetadata,
+		requestMetadata: requestMetadata,
+		taskMetadata:    taskMetadata,
+		useBSONObjectID: task.AppMetadata.Maintenance.ObjectIDMigration.Status != "done",
 	}
 }
@@ -0,0 +1,110 @@
+package flex
+
+import (
+	"testing"
+)
+
+func benchmarkTask() *Task {
+	return &Task{
+		AppID:    "kid_abc123",
+		TaskID:   "task-1",
+		TaskType: "data",
+		Method:   "GET",
+		AppMetadata: kinveyAppMetadata{
+			ID:           "kid_abc123",
+			AppSecret:    "appsecret",
+			MasterSecret: "mastersecret",
+			BaaSURL:      "https://baas.kinvey.com",
+		},
+		Request: Request{
+			Method:            "GET",
+			ServiceObjectName: "widgets",
+			Headers: map[string]string{
+				"authorization":               "Basic dXNlcjpwYXNzd29yZA==",
+				"host":                        "baas.kinvey.com",
+				"x-kinvey-client-app-version": "1.0.0",
+			},
+		},
+		Response: Response{
+			Headers: map[string]string{
+				"x-kinvey-api-version": "4",
+			},
+		},
+	}
+}
+
+var modulesSink Modules
+
+func BenchmarkGenerateModules(b *testing.B) {
+	baas := newBaaSClient(NewOptions("", 10001, ""))
+	task := benchmarkTask()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		modulesSink = generateModules(task, baas)
+	}
+}
+
+func BenchmarkGenerateModulesDataStore(b *testing.B) {
+	baas := newBaaSClient(NewOptions("", 10001, ""))
+	task := benchmarkTask()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		modules := generateModules(task, baas)
+		modules.DataStore().NewDataStore(true, true)
+	}
+}
+
+func BenchmarkGenerateModulesAll(b *testing.B) {
+	baas := newBaaSClient(NewOptions("", 10001, ""))
+	task := benchmarkTask()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		modules := generateModules(task, baas)
+		modules.BackendContext()
+		modules.DataStore()
+		modules.Email()
+		modules.EndpointRunner()
+		modules.KinveyEntity()
+		modules.RoleStore()
+		modules.TempObjectStore()
+		modules.UserStore()
+		modules.GroupStore()
+		modules.Logger()
+	}
+}
+
+func TestModulesAreBuiltOnce(t *testing.T) {
+	modules := generateModules(benchmarkTask(), newBaaSClient(NewOptions("", 10001, "")))
+
+	modules.TempObjectStore().Set("key", "value")
+
+	if modules.TempObjectStore().Get("key") != "value" {
+		t.Fatal("TempObjectStore was rebuilt between accesses")
+	}
+
+	if modules.BackendContext().GetAppKey() != "kid_abc123" {
+		t.Fatalf("unexpected app key %q", modules.BackendContext().GetAppKey())
+	}
+}
+
+func TestZeroModules(t *testing.T) {
+	var modules Modules
+
+	modules.BackendContext()
+	modules.DataStore()
+	modules.Email()
+	modules.EndpointRunner()
+	modules.KinveyEntity()
+	modules.RoleStore()
+	modules.TempObjectStore()
+	modules.UserStore()
+	modules.GroupStore()
+	modules.Logger()
+
+	if modules.RequestContext().GetSecurityContext() != SecurityContextUnknown {
+		t.Fatal("expected an empty task to have an unknown security context")
+	}
+}
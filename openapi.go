@@ -0,0 +1,373 @@
+package flex
+
+import (
+	"sort"
+)
+
+// OpenAPIOptions describes the service in the document from Flex.OpenAPI.
+type OpenAPIOptions struct {
+	// Title defaults to "Flex Service".
+	Title string
+	// Version is the version of the service. Defaults to the flex-go version.
+	Version string
+	// Servers are the base URLs the service is reached at, such as
+	// "https://example.com/flex". RegisterRoutes adds the group's base path
+	// when none are set.
+	Servers []string
+}
+
+type openAPIDocument struct {
+	OpenAPI string                                  `json:"openapi"`
+	Info    openAPIInfo                             `json:"info"`
+	Servers []openAPIServer                         `json:"servers,omitempty"`
+	Paths   map[string]map[string]*openAPIOperation `json:"paths"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIServer struct {
+	URL string `json:"url"`
+}
+
+type openAPIOperation struct {
+	OperationID string                     `json:"operationId"`
+	Summary     string                     `json:"summary,omitempty"`
+	Description string                     `json:"description,omitempty"`
+	Tags        []string                   `json:"tags,omitempty"`
+	Parameters  []openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIMediaType struct {
+	Schema *openAPISchema `json:"schema"`
+}
+
+// openAPISchema is a Schema with the OpenAPI keywords that are not used to
+// validate request bodies.
+type openAPISchema struct {
+	*Schema
+	OneOf []*Schema `json:"oneOf,omitempty"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+// openAPIRoute is where the HTTP receiver routes a data operation.
+type openAPIRoute struct {
+	path   string
+	method string
+	// query is set for operations that take a Mongo-style query.
+	query bool
+	// body is set for operations that take an entity.
+	body bool
+	// insert is set for the operations that share the insert route, which
+	// takes an entity or an array of them.
+	insert bool
+}
+
+var openAPIDataRoutes = map[string]openAPIRoute{
+	"onInsert":          {path: "", method: "post", insert: true},
+	"onInsertMany":      {path: "", method: "post", insert: true},
+	"onGetAll":          {path: "", method: "get"},
+	"onGetByQuery":      {path: "", method: "get", query: true},
+	"onDeleteAll":       {path: "", method: "delete"},
+	"onDeleteByQuery":   {path: "", method: "delete", query: true},
+	"onGetCount":        {path: "/_count", method: "get"},
+	"onGetCountByQuery": {path: "/_count", method: "get", query: true},
+	"onGetByID":         {path: "/{id}", method: "get"},
+	"onUpdate":          {path: "/{id}", method: "put", body: true},
+	"onPatch":           {path: "/{id}", method: "patch", body: true},
+	"onDeleteByID":      {path: "/{id}", method: "delete"},
+}
+
+// OpenAPI describes the service's HTTP routes as an OpenAPI 3 document.
+// Only implemented data operations are included, and typed handlers and
+// Describe supply the request and response schemas. See
+// Options.SetOpenAPI for the title, version and servers.
+func (s Flex) OpenAPI() ([]byte, error) {
+	doc := openAPIDocument{
+		OpenAPI: "3.0.3",
+		Info: openAPIInfo{
+			Title:   s.openAPI.Title,
+			Version: s.openAPI.Version,
+		},
+		Paths: make(map[string]map[string]*openAPIOperation),
+	}
+	if doc.Info.Title == "" {
+		doc.Info.Title = "Flex Service"
+	}
+	if doc.Info.Version == "" {
+		doc.Info.Version = s.version
+	}
+	for _, server := range s.openAPI.Servers {
+		doc.Servers = append(doc.Servers, openAPIServer{URL: server})
+	}
+
+	for name, so := range s.Data.discover() {
+		addServiceObjectPaths(doc.Paths, name, so)
+	}
+
+	for name, metadata := range s.Functions.discover() {
+		addFunctionPath(doc.Paths, name, metadata)
+	}
+
+	for name, metadata := range s.Auth.discover() {
+		addAuthPath(doc.Paths, name, metadata)
+	}
+
+	return json.Marshal(doc)
+}
+
+func addServiceObjectPaths(paths map[string]map[string]*openAPIOperation, name string, so ServiceObjectMetadata) {
+	// visit operations in a fixed order so merged routes are stable
+	dataOps := make([]string, 0, len(so.Operations))
+	for dataOp := range so.Operations {
+		dataOps = append(dataOps, dataOp)
+	}
+	sort.Strings(dataOps)
+
+	for _, dataOp := range dataOps {
+		route, ok := openAPIDataRoutes[dataOp]
+		if !ok {
+			continue
+		}
+		metadata := so.Operations[dataOp]
+
+		path := "/" + name + route.path
+		if paths[path] == nil {
+			paths[path] = make(map[string]*openAPIOperation)
+		}
+
+		op := paths[path][route.method]
+		if op == nil {
+			op = &openAPIOperation{
+				OperationID: name + "_" + dataOp,
+				Tags:        []string{name},
+				Responses:   make(map[string]openAPIResponse),
+			}
+			if route.path == "/{id}" {
+				op.Parameters = append(op.Parameters, openAPIParameter{
+					Name:     "id",
+					In:       "path",
+					Required: true,
+					Schema:   &Schema{Type: "string"},
+				})
+			}
+			op.Responses["default"] = errorResponse()
+			paths[path][route.method] = op
+		} else {
+			// routes shared by several operations, e.g. onGetAll and onGetByQuery
+			op.OperationID += "_" + dataOp
+		}
+
+		if op.Summary == "" {
+			op.Summary = so.Description
+		}
+		if metadata.Description != "" {
+			if op.Description != "" {
+				op.Description += "\n\n"
+			}
+			op.Description += metadata.Description
+		}
+
+		if route.query {
+			op.Parameters = append(op.Parameters, openAPIParameter{
+				Name:        "query",
+				In:          "query",
+				Description: "A MongoDB-style query, as JSON",
+				Schema:      &Schema{Type: "string"},
+			})
+		}
+
+		if route.body && op.RequestBody == nil {
+			op.RequestBody = jsonRequestBody(metadata.InputSchema)
+		}
+
+		if route.insert {
+			describeInsert(op, so)
+			continue
+		}
+
+		if _, ok := op.Responses["200"]; !ok || metadata.OutputSchema != nil {
+			op.Responses["200"] = jsonResponse(metadata.OutputSchema)
+		}
+	}
+}
+
+// describeInsert describes the insert route, which takes one entity or an
+// array of them. Arrays are handled by onInsertMany, or else by onInsert once
+// per entity, and answered with a 207 multi-insert response.
+func describeInsert(op *openAPIOperation, so ServiceObjectMetadata) {
+	entity := so.Operations["onInsert"].InputSchema
+	if entity == nil {
+		entity = &Schema{}
+	}
+	entities := so.Operations["onInsertMany"].InputSchema
+	if entities == nil {
+		entities = &Schema{Type: "array", Items: entity}
+	}
+
+	op.RequestBody = &openAPIRequestBody{
+		Required: true,
+		Content: map[string]openAPIMediaType{
+			"application/json": {Schema: &openAPISchema{OneOf: []*Schema{entity, entities}}},
+		},
+	}
+
+	// typed inserts reply with a 201, other handlers choose their status
+	output := so.Operations["onInsert"].OutputSchema
+	op.Responses["200"] = jsonResponse(output)
+	op.Responses["201"] = jsonResponse(output)
+
+	if output == nil {
+		output = &Schema{}
+	}
+	op.Responses["207"] = openAPIResponse{
+		Description: "Entities inserted from an array, with the index of each entity that failed",
+		Content: map[string]openAPIMediaType{
+			"application/json": {Schema: &openAPISchema{Schema: &Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"entities": {Type: "array", Items: output},
+					"errors":   {Type: "array", Items: SchemaFor[multiInsertError]()},
+				},
+			}}},
+		},
+	}
+}
+
+// addFunctionPath describes a function route. Requests wrap the input in a
+// body member, and replies echo the request with the handler's status and
+// body in response, so a failed function still answers with a 200.
+func addFunctionPath(paths map[string]map[string]*openAPIOperation, name string, metadata HandlerMetadata) {
+	output := metadata.OutputSchema
+	if output == nil {
+		output = &Schema{}
+	}
+
+	paths["/_flexFunctions/"+name] = map[string]*openAPIOperation{
+		"post": {
+			OperationID: "functions_" + name,
+			Summary:     metadata.Description,
+			Tags:        []string{"functions"},
+			RequestBody: jsonRequestBody(handlerRequestSchema(metadata.InputSchema)),
+			Responses: map[string]openAPIResponse{
+				"200": jsonResponse(&Schema{
+					Type: "object",
+					Properties: map[string]*Schema{
+						"request": {Type: "object", Description: "The request the function received"},
+						"response": {
+							Type:        "object",
+							Description: "The function's reply. A failed function sets an error status and a body with error, description and debug members.",
+							Properties: map[string]*Schema{
+								"status":  {Type: "integer"},
+								"headers": {Type: "object"},
+								"body":    output,
+							},
+						},
+					},
+				}),
+				"404": {Description: "No function is registered with this name"},
+			},
+		},
+	}
+}
+
+// addAuthPath describes an auth route. Requests wrap the input in a body
+// member, and replies carry the token or an OAuth 2.0 style error.
+func addAuthPath(paths map[string]map[string]*openAPIOperation, name string, metadata HandlerMetadata) {
+	paths["/_auth/"+name] = map[string]*openAPIOperation{
+		"post": {
+			OperationID: "auth_" + name,
+			Summary:     metadata.Description,
+			Tags:        []string{"auth"},
+			RequestBody: jsonRequestBody(handlerRequestSchema(metadata.InputSchema)),
+			Responses: map[string]openAPIResponse{
+				"200": jsonResponse(SchemaFor[authCompletionResponse]()),
+				"404": {Description: "No auth handler is registered with this name"},
+				"default": {
+					Description: "Error",
+					Content: map[string]openAPIMediaType{
+						"application/json": {Schema: &openAPISchema{Schema: SchemaFor[authError]()}},
+					},
+				},
+			},
+		},
+	}
+}
+
+// handlerRequestSchema describes the envelope the HTTP receiver expects for
+// function and auth requests, with the handler's input in body.
+func handlerRequestSchema(input *Schema) *Schema {
+	if input == nil {
+		input = &Schema{}
+	}
+
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"body":            input,
+			"query":           {Type: "string", Description: "A query string, passed to the handler as its Query"},
+			"method":          {Type: "string"},
+			"hookType":        {Type: "string"},
+			"objectName":      {Type: "string"},
+			"entityId":        {Type: "string"},
+			"tempObjectStore": {Type: "object"},
+		},
+		Required: []string{"body"},
+	}
+}
+
+func jsonRequestBody(schema *Schema) *openAPIRequestBody {
+	if schema == nil {
+		schema = &Schema{}
+	}
+
+	return &openAPIRequestBody{
+		Required: true,
+		Content: map[string]openAPIMediaType{
+			"application/json": {Schema: &openAPISchema{Schema: schema}},
+		},
+	}
+}
+
+func jsonResponse(schema *Schema) openAPIResponse {
+	if schema == nil {
+		schema = &Schema{}
+	}
+
+	return openAPIResponse{
+		Description: "Success",
+		Content: map[string]openAPIMediaType{
+			"application/json": {Schema: &openAPISchema{Schema: schema}},
+		},
+	}
+}
+
+func errorResponse() openAPIResponse {
+	return openAPIResponse{
+		Description: "Error",
+		Content: map[string]openAPIMediaType{
+			"application/json": {Schema: &openAPISchema{Schema: SchemaFor[KinveyError]()}},
+		},
+	}
+}
@@ -0,0 +1,145 @@
+package flex
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestOpenAPI(t *testing.T) {
+	s := newFlex(NewOptions("", 10001, ""))
+
+	respond := func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task) {
+		return complete.OK().Done()
+	}
+
+	widgets := s.Data.NewServiceObject("widgets")
+	widgets.OnGetAll(respond)
+	widgets.OnGetByQuery(respond)
+	widgets.OnGetByID(respond)
+	OnInsertTyped(widgets, func(context *Request, entity discoveryWidget, modules Modules) (discoveryWidget, error) {
+		return entity, nil
+	})
+	RegisterTyped(s.Functions, "price", func(context *Request, input discoveryWidget, modules Modules) (float64, error) {
+		return input.Price, nil
+	})
+	s.Auth.Register("login", func(context *Request, complete AuthCompletionHandler, modules Modules) (*Task, *Task) {
+		return complete.Done()
+	})
+
+	body, err := s.OpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc openAPIDocument
+	if err := json.Unmarshal(body, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.OpenAPI != "3.0.3" {
+		t.Errorf("got openapi %q", doc.OpenAPI)
+	}
+
+	want := map[string][]string{
+		"/widgets":              {"get", "post"},
+		"/widgets/{id}":         {"get"},
+		"/_flexFunctions/price": {"post"},
+		"/_auth/login":          {"post"},
+	}
+	if len(doc.Paths) != len(want) {
+		t.Errorf("got paths %v", doc.Paths)
+	}
+	for path, methods := range want {
+		if len(doc.Paths[path]) != len(methods) {
+			t.Errorf("%s: got %d methods, want %v", path, len(doc.Paths[path]), methods)
+		}
+		for _, method := range methods {
+			if doc.Paths[path][method] == nil {
+				t.Errorf("%s: missing %s", path, method)
+			}
+		}
+	}
+
+	get := doc.Paths["/widgets"]["get"]
+	if get.OperationID != "widgets_onGetAll_onGetByQuery" || len(get.Parameters) != 1 || get.Parameters[0].Name != "query" {
+		t.Errorf("GET /widgets was not merged: %+v", get)
+	}
+
+	// inserts take an entity or an array of them
+	post := doc.Paths["/widgets"]["post"]
+	oneOf := post.RequestBody.Content["application/json"].Schema.OneOf
+	if len(oneOf) != 2 || oneOf[0].Properties["name"] == nil || oneOf[1].Type != "array" || oneOf[1].Items.Properties["name"] == nil {
+		t.Errorf("POST /widgets did not take the typed entity or an array of them: %+v", oneOf)
+	}
+	multiInsert := post.Responses["207"].Content["application/json"].Schema
+	if multiInsert == nil || multiInsert.Properties["entities"] == nil || multiInsert.Properties["errors"] == nil {
+		t.Errorf("POST /widgets did not describe the multi-insert response")
+	}
+
+	// functions and auth handlers are described with the receiver's envelopes
+	price := doc.Paths["/_flexFunctions/price"]["post"]
+	if input := price.RequestBody.Content["application/json"].Schema.Properties["body"]; input == nil || input.Properties["name"] == nil {
+		t.Errorf("function input schema was not wrapped in body: %+v", price.RequestBody.Content["application/json"].Schema)
+	}
+	reply := price.Responses["200"].Content["application/json"].Schema
+	if reply.Properties["request"] == nil || reply.Properties["response"] == nil || reply.Properties["response"].Properties["body"].Type != "number" {
+		t.Errorf("function output schema was not wrapped in response.body: %+v", reply)
+	}
+
+	login := doc.Paths["/_auth/login"]["post"]
+	if login.RequestBody.Content["application/json"].Schema.Properties["body"] == nil {
+		t.Errorf("auth input was not wrapped in body")
+	}
+	if login.Responses["200"].Content["application/json"].Schema.Properties["token"] == nil {
+		t.Errorf("auth reply did not describe the token")
+	}
+	if login.Responses["default"].Content["application/json"].Schema.Properties["error_description"] == nil {
+		t.Errorf("auth errors were not described as auth errors")
+	}
+}
+
+func TestOpenAPIOptions(t *testing.T) {
+	s := newFlex(NewOptions("", 10001, "").SetOpenAPI(OpenAPIOptions{
+		Title:   "Widgets",
+		Version: "2.1.0",
+		Servers: []string{"https://example.com/flex"},
+	}))
+
+	body, err := s.OpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc openAPIDocument
+	if err := json.Unmarshal(body, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Info.Title != "Widgets" || doc.Info.Version != "2.1.0" || len(doc.Servers) != 1 || doc.Servers[0].URL != "https://example.com/flex" {
+		t.Errorf("options were not used: %s", body)
+	}
+
+	body, _ = newFlex(NewOptions("", 10001, "")).OpenAPI()
+	json.Unmarshal(body, &doc)
+	if doc.Info.Version != flexGoVersion {
+		t.Errorf("expected the version to default to %s, got %s", flexGoVersion, doc.Info.Version)
+	}
+}
+
+func TestRegisterRoutesAddsServer(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	RegisterRoutes(router.Group("/flex"), NewOptions("", 10001, ""), func(err error, flex Flex) {})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/flex/_command/openapi", nil))
+
+	var doc openAPIDocument
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("%v: %s", err, w.Body.String())
+	}
+	if len(doc.Servers) != 1 || doc.Servers[0].URL != "/flex" {
+		t.Errorf("expected the group's base path as the server, got %+v", doc.Servers)
+	}
+}
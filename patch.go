@@ -0,0 +1,355 @@
+package flex
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Patch is a decoded PATCH body: either a JSON merge patch (RFC 7396) when the
+// body is an object, or a JSON patch (RFC 6902) when it is an array of
+// operations.
+type Patch struct {
+	merge      map[string]interface{}
+	operations []patchOperation
+}
+
+type patchOperation struct {
+	Op   string `json:"op"`
+	Path string `json:"path"`
+	From string `json:"from"`
+
+	value interface{}
+	// hasValue tells a missing value apart from null.
+	hasValue bool
+}
+
+func (op *patchOperation) UnmarshalJSON(data []byte) error {
+	type operation patchOperation
+	if err := json.Unmarshal(data, (*operation)(op)); err != nil {
+		return err
+	}
+
+	var members map[string]interface{}
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+	op.value, op.hasValue = members["value"]
+	return nil
+}
+
+// DecodePatch ...
+func DecodePatch(body []byte) (*Patch, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil, errors.New("Patch body is required")
+	}
+
+	p := &Patch{}
+
+	if body[0] == '[' {
+		err := json.Unmarshal(body, &p.operations)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range p.operations {
+			op := &p.operations[i]
+
+			switch op.Op {
+			case "add", "replace", "test":
+				if !op.hasValue {
+					return nil, errors.New("Patch operation " + op.Op + " requires a value")
+				}
+			case "remove":
+			case "move", "copy":
+				if _, err := parsePointer(op.From); err != nil {
+					return nil, err
+				}
+				// a location cannot be moved into one of its children
+				if op.Op == "move" && strings.HasPrefix(op.Path, op.From+"/") {
+					return nil, errors.New("Cannot move " + op.From + " into " + op.Path)
+				}
+			default:
+				return nil, errors.New("Unsupported patch operation " + op.Op)
+			}
+			if _, err := parsePointer(op.Path); err != nil {
+				return nil, err
+			}
+		}
+
+		return p, nil
+	}
+
+	if body[0] == '{' {
+		err := json.Unmarshal(body, &p.merge)
+		if err != nil {
+			return nil, err
+		}
+		return p, nil
+	}
+
+	return nil, errors.New("Patch body must be a JSON object or an array of operations")
+}
+
+// Patch decodes the request body as a JSON merge patch or JSON patch.
+func (r *Request) Patch() (*Patch, error) {
+	return DecodePatch(r.Body)
+}
+
+// IsMergePatch ...
+func (p *Patch) IsMergePatch() bool {
+	return p.merge != nil
+}
+
+// Apply returns document with the patch applied.
+func (p *Patch) Apply(document []byte) ([]byte, error) {
+	var doc interface{}
+
+	err := json.Unmarshal(document, &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.IsMergePatch() {
+		doc = mergePatch(doc, p.merge)
+	} else {
+		for _, op := range p.operations {
+			doc, err = op.apply(doc)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return json.Marshal(doc)
+}
+
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = make(map[string]interface{})
+	}
+
+	for key, value := range p {
+		if value == nil {
+			delete(t, key)
+		} else {
+			t[key] = mergePatch(t[key], value)
+		}
+	}
+
+	return t
+}
+
+func (op patchOperation) apply(doc interface{}) (interface{}, error) {
+	path, _ := parsePointer(op.Path)
+
+	// an empty path targets the whole document
+	if len(path) == 0 {
+		switch op.Op {
+		case "add", "replace":
+			return deepCopy(op.value), nil
+		case "remove":
+			return nil, nil
+		}
+	}
+
+	switch op.Op {
+	case "add":
+		return updatePointer(doc, path, func(node interface{}, key string) (interface{}, error) {
+			return addValue(node, key, deepCopy(op.value))
+		})
+	case "remove":
+		return updatePointer(doc, path, removeValue)
+	case "replace":
+		return updatePointer(doc, path, func(node interface{}, key string) (interface{}, error) {
+			node, err := removeValue(node, key)
+			if err != nil {
+				return nil, err
+			}
+			return addValue(node, key, deepCopy(op.value))
+		})
+	case "move", "copy":
+		from, _ := parsePointer(op.From)
+
+		value, err := getPointer(doc, from)
+		if err != nil {
+			return nil, err
+		}
+
+		if op.Op == "move" && len(from) > 0 {
+			doc, err = updatePointer(doc, from, removeValue)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			value = deepCopy(value)
+		}
+
+		if len(path) == 0 {
+			return value, nil
+		}
+
+		return updatePointer(doc, path, func(node interface{}, key string) (interface{}, error) {
+			return addValue(node, key, value)
+		})
+	case "test":
+		value, err := getPointer(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(value, op.value) {
+			return nil, errors.New("Patch test failed at " + op.Path)
+		}
+		return doc, nil
+	}
+
+	return nil, errors.New("Unsupported patch operation " + op.Op)
+}
+
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, errors.New("Invalid JSON pointer " + pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i := range tokens {
+		tokens[i] = strings.Replace(strings.Replace(tokens[i], "~1", "/", -1), "~0", "~", -1)
+	}
+	return tokens, nil
+}
+
+// updatePointer walks doc to the parent of a non-empty path and replaces that
+// parent with the result of update, returning the new document.
+func updatePointer(doc interface{}, path []string, update func(node interface{}, key string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return update(doc, path[0])
+	}
+
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		child, ok := node[path[0]]
+		if !ok {
+			return nil, errors.New("Path not found: " + path[0])
+		}
+		child, err := updatePointer(child, path[1:], update)
+		if err != nil {
+			return nil, err
+		}
+		node[path[0]] = child
+		return node, nil
+	case []interface{}:
+		i, err := arrayIndex(node, path[0], false)
+		if err != nil {
+			return nil, err
+		}
+		child, err := updatePointer(node[i], path[1:], update)
+		if err != nil {
+			return nil, err
+		}
+		node[i] = child
+		return node, nil
+	}
+
+	return nil, errors.New("Path not found: " + path[0])
+}
+
+func getPointer(doc interface{}, path []string) (interface{}, error) {
+	for _, key := range path {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			child, ok := node[key]
+			if !ok {
+				return nil, errors.New("Path not found: " + key)
+			}
+			doc = child
+		case []interface{}:
+			i, err := arrayIndex(node, key, false)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[i]
+		default:
+			return nil, errors.New("Path not found: " + key)
+		}
+	}
+	return doc, nil
+}
+
+func addValue(node interface{}, key string, value interface{}) (interface{}, error) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		n[key] = value
+		return n, nil
+	case []interface{}:
+		i, err := arrayIndex(n, key, true)
+		if err != nil {
+			return nil, err
+		}
+		n = append(n, nil)
+		copy(n[i+1:], n[i:])
+		n[i] = value
+		return n, nil
+	}
+	return nil, errors.New("Cannot add to a scalar value")
+}
+
+func removeValue(node interface{}, key string) (interface{}, error) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if _, ok := n[key]; !ok {
+			return nil, errors.New("Path not found: " + key)
+		}
+		delete(n, key)
+		return n, nil
+	case []interface{}:
+		i, err := arrayIndex(n, key, false)
+		if err != nil {
+			return nil, err
+		}
+		return append(n[:i], n[i+1:]...), nil
+	}
+	return nil, errors.New("Cannot remove from a scalar value")
+}
+
+func arrayIndex(array []interface{}, key string, appending bool) (int, error) {
+	if appending && key == "-" {
+		return len(array), nil
+	}
+
+	i, err := strconv.Atoi(key)
+	if err != nil || i < 0 {
+		return 0, errors.New("Invalid array index " + key)
+	}
+
+	max := len(array) - 1
+	if appending {
+		max = len(array)
+	}
+	if i > max {
+		return 0, errors.New("Array index out of range " + key)
+	}
+
+	return i, nil
+}
+
+func deepCopy(value interface{}) interface{} {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var c interface{}
+	json.Unmarshal(b, &c)
+	return c
+}
@@ -0,0 +1,117 @@
+package flex
+
+// Policy declares which callers may run a handler. A request is allowed when
+// any of the listed conditions holds; the zero Policy allows no one.
+// Requests without recognisable credentials are rejected with a 401, and
+// callers the policy does not allow with a 403.
+type Policy struct {
+	// Master allows requests made with the master secret.
+	Master bool
+	// App allows requests made with the app secret.
+	App bool
+	// Users allows any authenticated user.
+	Users bool
+	// Roles allows authenticated users that are members of any of these
+	// roles, given by role ID rather than name. Membership is looked up with
+	// the RoleStore, once per task.
+	Roles []string
+}
+
+// roleMember is an entry of a role's membership list. The user is matched on
+// userId only; _id identifies the membership entry, not the user.
+type roleMember struct {
+	UserID string `json:"userId"`
+}
+
+func enforcePolicy(policy *Policy, next HandlerFunc) HandlerFunc {
+	return func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task) {
+		requestContext := modules.RequestContext()
+
+		switch requestContext.GetSecurityContext() {
+		case SecurityContextMaster:
+			if policy.Master {
+				return next(context, complete, modules)
+			}
+		case SecurityContextApp:
+			if policy.App {
+				return next(context, complete, modules)
+			}
+		case SecurityContextUser:
+			if policy.Users {
+				return next(context, complete, modules)
+			}
+			if len(policy.Roles) > 0 {
+				allowed, err := hasAnyRole(modules, requestContext.GetAuthenticatedUserID(), policy.Roles)
+				if err != nil {
+					return complete.RuntimeError("Unable to check role membership: " + err.Error()).Done()
+				}
+				if allowed {
+					return next(context, complete, modules)
+				}
+			}
+		default:
+			return complete.InsufficientCredentials("No valid credentials were provided").Done()
+		}
+
+		return complete.Forbidden("Requests with " + requestContext.GetCredentialType() + " credentials are not allowed to run this operation").Done()
+	}
+}
+
+func hasAnyRole(modules Modules, userID string, roles []string) (bool, error) {
+	if userID == "" {
+		return false, nil
+	}
+
+	l := modules.modules()
+	roleStore := modules.RoleStore().NewRoleStore(false)
+
+	for _, role := range roles {
+		member, err := l.isRoleMember(roleStore, role, userID)
+		if err != nil {
+			return false, err
+		}
+		if member {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// isRoleMember looks up whether the user is a member of the role once per
+// task, so handlers that run more than once for a task, such as onInsert for
+// each entity of an insert, do not repeat the lookup.
+func (l *lazyModules) isRoleMember(roleStore RoleStore, role string, userID string) (bool, error) {
+	l.roleMembersMu.Lock()
+	defer l.roleMembersMu.Unlock()
+
+	if member, ok := l.roleMembers[role]; ok {
+		return member, nil
+	}
+
+	body, err := roleStore.ListMembers(role)
+	if err != nil {
+		return false, err
+	}
+
+	var members []roleMember
+	err = json.Unmarshal(body, &members)
+	if err != nil {
+		return false, err
+	}
+
+	member := false
+	for _, m := range members {
+		if m.UserID == userID {
+			member = true
+			break
+		}
+	}
+
+	if l.roleMembers == nil {
+		l.roleMembers = make(map[string]bool)
+	}
+	l.roleMembers[role] = member
+
+	return member, nil
+}
@@ -0,0 +1,81 @@
+package flex
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const adminsRoleID = "0f350bba-1145-e342-cb5a-223f314b650d"
+
+func policyTask(baasURL string, authorization string, userID string) *Task {
+	return &Task{
+		AppID:    "kid_abc123",
+		TaskType: "data",
+		BaaSURL:  baasURL,
+		AppMetadata: kinveyAppMetadata{
+			ID:           "kid_abc123",
+			AppSecret:    "appsecret",
+			MasterSecret: "mastersecret",
+		},
+		Request: Request{
+			Method:            "DELETE",
+			ServiceObjectName: "widgets",
+			UserID:            userID,
+			Headers:           map[string]string{"authorization": authorization},
+		},
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	lookups := 0
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/roles/kid_abc123/"+adminsRoleID+"/membership" {
+			http.NotFound(w, r)
+			return
+		}
+		lookups++
+		// the _id of a membership entry is not a user ID
+		w.Write([]byte(`[{"_id":"other-user","userId":"admin-user"}]`))
+	}))
+	defer backend.Close()
+
+	policy := &Policy{Master: true, Roles: []string{adminsRoleID}}
+	handler := handlerOptions{policy: policy}.wrap(func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task) {
+		return complete.OK().Done()
+	})
+
+	tests := []struct {
+		authorization string
+		userID        string
+		want          int
+	}{
+		{"", "", 401},
+		{basic("kid_abc123:mastersecret"), "", 200},
+		{basic("kid_abc123:appsecret"), "", 403},
+		{"Kinvey session-token", "admin-user", 200},
+		{"Kinvey session-token", "other-user", 403},
+	}
+
+	baas := newBaaSClient(NewOptions("", 10001, ""))
+
+	for _, test := range tests {
+		task := policyTask(backend.URL, test.authorization, test.userID)
+
+		_, result := handler(&task.Request, NewKinveyCompletionHandler(task), generateModules(task, baas))
+		if result.Response.Status != test.want {
+			t.Errorf("%q as %q got status %d, want %d", test.authorization, test.userID, result.Response.Status, test.want)
+		}
+	}
+
+	// a handler that runs more than once for a task looks membership up once
+	lookups = 0
+	task := policyTask(backend.URL, "Kinvey session-token", "admin-user")
+	modules := generateModules(task, baas)
+	for i := 0; i < 3; i++ {
+		handler(&task.Request, NewKinveyCompletionHandler(task), modules)
+	}
+	if lookups != 1 {
+		t.Fatalf("expected 1 membership lookup for the task, got %d", lookups)
+	}
+}
@@ -0,0 +1,197 @@
+package flex
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rate limit keys. RateLimitByUser falls back to the client IP for requests
+// without an authenticated user, and RateLimitByIP falls back to the user, then
+// the app, for requests without a client address.
+const (
+	RateLimitByUser = "user"
+	RateLimitByApp  = "app"
+	RateLimitByIP   = "ip"
+)
+
+// RateLimit allows Requests per Per for each key, with bursts of up to Burst
+// requests. Requests over the limit are rejected with a 429.
+type RateLimit struct {
+	Requests int
+	Per      time.Duration
+	// Burst defaults to Requests.
+	Burst int
+	// By is one of the RateLimitBy constants. Defaults to RateLimitByUser.
+	By string
+	// TrustedHops is the number of proxies, Kinvey's included, that append
+	// to X-Forwarded-For in front of the service. The client IP is the entry
+	// the outermost of them appended; entries to its left are set by the
+	// caller and are ignored. Defaults to 1, the rightmost entry.
+	TrustedHops int
+}
+
+// rateLimitMaxBuckets caps the number of keys tracked at once. Past it, the
+// least recently used bucket is evicted.
+const rateLimitMaxBuckets = 10000
+
+// rateLimiter is a set of token buckets, one per key.
+type rateLimiter struct {
+	mu        sync.Mutex
+	rate      float64
+	burst     float64
+	by        string
+	hops      int
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter returns nil when limit does not restrict anything.
+func newRateLimiter(limit RateLimit) *rateLimiter {
+	if limit.Requests <= 0 || limit.Per <= 0 {
+		return nil
+	}
+
+	burst := limit.Burst
+	if burst <= 0 {
+		burst = limit.Requests
+	}
+
+	by := limit.By
+	if by == "" {
+		by = RateLimitByUser
+	}
+
+	hops := limit.TrustedHops
+	if hops <= 0 {
+		hops = 1
+	}
+
+	return &rateLimiter{
+		rate:      float64(limit.Requests) / limit.Per.Seconds(),
+		burst:     float64(burst),
+		by:        by,
+		hops:      hops,
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// allow takes a token from key's bucket. When the bucket is empty it reports
+// how long until the next token.
+func (l *rateLimiter) allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= rateLimitMaxBuckets {
+			l.evictOldest()
+		}
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * l.rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+
+	b.tokens--
+	return true, 0
+}
+
+// sweep drops buckets that have refilled, since they behave exactly like new
+// ones. It runs at most once per refill period.
+func (l *rateLimiter) sweep(now time.Time) {
+	refill := time.Duration(l.burst / l.rate * float64(time.Second))
+	if now.Sub(l.lastSweep) < refill {
+		return
+	}
+
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= refill {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+func (l *rateLimiter) evictOldest() {
+	var oldestKey string
+	var oldest time.Time
+
+	for key, b := range l.buckets {
+		if oldestKey == "" || b.last.Before(oldest) {
+			oldestKey, oldest = key, b.last
+		}
+	}
+	delete(l.buckets, oldestKey)
+}
+
+func (l *rateLimiter) key(context *Request, modules Modules) string {
+	requestContext := modules.RequestContext()
+	userID := requestContext.GetAuthenticatedUserID()
+
+	switch l.by {
+	case RateLimitByApp:
+		return "app:" + modules.BackendContext().GetAppKey()
+	case RateLimitByUser:
+		if userID != "" {
+			return "user:" + userID
+		}
+	}
+
+	if ip := clientIP(context.Headers["x-forwarded-for"], l.hops); ip != "" {
+		return "ip:" + ip
+	}
+	if userID != "" {
+		return "user:" + userID
+	}
+	return "app:" + modules.BackendContext().GetAppKey()
+}
+
+func limitRate(limiter *rateLimiter, next HandlerFunc) HandlerFunc {
+	return func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task) {
+		if ok, wait := limiter.allow(limiter.key(context, modules)); !ok {
+			return complete.TooManyRequests("Rate limit exceeded, retry in " + wait.Round(time.Millisecond).String()).Done()
+		}
+
+		return next(context, complete, modules)
+	}
+}
+
+// clientIP returns the X-Forwarded-For entry appended by the outermost of
+// trustedHops proxies, counting from the right. Entries further left are
+// supplied by the caller and cannot be trusted.
+func clientIP(forwardedFor string, trustedHops int) string {
+	var entries []string
+	for _, entry := range strings.Split(forwardedFor, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+
+	if len(entries) == 0 {
+		return ""
+	}
+
+	i := len(entries) - trustedHops
+	if i < 0 {
+		i = 0
+	}
+	return entries[i]
+}
@@ -0,0 +1,116 @@
+package flex
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter := newRateLimiter(RateLimit{Requests: 2, Per: time.Minute})
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := limiter.allow("alice"); !ok {
+			t.Fatalf("request %d was limited", i)
+		}
+	}
+
+	ok, wait := limiter.allow("alice")
+	if ok {
+		t.Fatal("request over the limit was allowed")
+	}
+	if wait <= 0 || wait > 30*time.Second {
+		t.Fatalf("unexpected wait %s", wait)
+	}
+
+	if ok, _ := limiter.allow("bob"); !ok {
+		t.Fatal("a different key was limited")
+	}
+}
+
+func TestLimitRateByIP(t *testing.T) {
+	handler := handlerOptions{rate: newRateLimiter(RateLimit{Requests: 1, Per: time.Minute, By: RateLimitByIP})}.wrap(func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task) {
+		return complete.OK().Done()
+	})
+
+	baas := newBaaSClient(NewOptions("", 10001, ""))
+	run := func(forwardedFor string) int {
+		task := &Task{
+			AppMetadata: kinveyAppMetadata{ID: "kid_abc123"},
+			Request: Request{
+				Headers: map[string]string{
+					"authorization":   "Kinvey session-token",
+					"x-forwarded-for": forwardedFor,
+				},
+			},
+		}
+
+		_, result := handler(&task.Request, NewKinveyCompletionHandler(task), generateModules(task, baas))
+		return result.Response.Status
+	}
+
+	if status := run("203.0.113.7"); status != 200 {
+		t.Fatalf("first request got %d", status)
+	}
+	// the caller controls every entry but the one Kinvey appended
+	if status := run("198.51.100.1, 203.0.113.7"); status != 429 {
+		t.Fatalf("request with a spoofed X-Forwarded-For got %d, want 429", status)
+	}
+	if status := run("198.51.100.1"); status != 200 {
+		t.Fatalf("request from another client got %d", status)
+	}
+}
+
+func TestRateLimitKeyFallsBackWithoutAddress(t *testing.T) {
+	baas := newBaaSClient(NewOptions("", 10001, ""))
+	limiter := newRateLimiter(RateLimit{Requests: 1, Per: time.Minute, By: RateLimitByIP})
+
+	task := &Task{AppMetadata: kinveyAppMetadata{ID: "kid_abc123"}}
+	if key := limiter.key(&task.Request, generateModules(task, baas)); key != "app:kid_abc123" {
+		t.Fatalf("unexpected key %q", key)
+	}
+
+	task.Request.Headers = map[string]string{"authorization": "Kinvey token"}
+	task.Request.UserID = "user-1"
+	if key := limiter.key(&task.Request, generateModules(task, baas)); key != "user:user-1" {
+		t.Fatalf("unexpected key %q", key)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	cases := []struct {
+		forwardedFor string
+		hops         int
+		want         string
+	}{
+		{"", 1, ""},
+		{"203.0.113.7", 1, "203.0.113.7"},
+		{"198.51.100.1, 203.0.113.7", 1, "203.0.113.7"},
+		{"198.51.100.1, 203.0.113.7, 10.0.0.1", 2, "203.0.113.7"},
+		{"203.0.113.7", 3, "203.0.113.7"},
+		{" , 203.0.113.7 ,", 1, "203.0.113.7"},
+	}
+
+	for _, c := range cases {
+		if got := clientIP(c.forwardedFor, c.hops); got != c.want {
+			t.Errorf("clientIP(%q, %d) = %q, want %q", c.forwardedFor, c.hops, got, c.want)
+		}
+	}
+}
+
+func TestRateLimiterEvictsAtCapacity(t *testing.T) {
+	limiter := newRateLimiter(RateLimit{Requests: 1, Per: time.Hour})
+
+	limiter.allow("first")
+	limiter.buckets["first"].last = time.Now().Add(-time.Minute)
+	for i := 0; i < rateLimitMaxBuckets; i++ {
+		limiter.allow(fmt.Sprint(i))
+	}
+
+	if len(limiter.buckets) != rateLimitMaxBuckets {
+		t.Fatalf("expected %d buckets, got %d", rateLimitMaxBuckets, len(limiter.buckets))
+	}
+	if _, ok := limiter.buckets["first"]; ok {
+		t.Fatal("expected the least recently used bucket to be evicted")
+	}
+}
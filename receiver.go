@@ -8,8 +8,11 @@ type receiver interface {
 }
 
 func newReceiver(options *Options) receiver {
-	if options.receiverType == receiverTypeHTTP {
+	switch options.receiverType {
+	case receiverTypeHTTP:
 		return &httpReceiver{}
+	case receiverTypeReplay:
+		return &replayReceiver{path: options.replayFile, ignore: options.replayIgnore}
 	}
 	return &tcpReceiver{}
 }
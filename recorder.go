@@ -0,0 +1,297 @@
+package flex
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+const redacted = "[REDACTED]"
+
+// redactedHeaders are headers whose values are never recorded.
+var redactedHeaders = []string{
+	"authorization",
+	"cookie",
+	"proxy-authorization",
+	"set-cookie",
+}
+
+// redactedFields are body fields whose values are never recorded, at any
+// depth.
+var redactedFields = []string{
+	"access_token",
+	"password",
+	"refresh_token",
+	"token",
+}
+
+// RecordingOptions configures task recording. Every task the service
+// receives is written with its response as one line of a JSONL recording,
+// which ReadRecordings, Flex.Replay and cmd/flexrun can replay. Secrets, such
+// as the auth key, app and master secrets, authorization headers, password
+// and token fields and the bodies of auth tasks, are redacted. Replays do not
+// compare redacted values.
+type RecordingOptions struct {
+	// Path is the file recordings are appended to.
+	Path string
+	// MaxSize rotates the file once it would grow past this many bytes. 0
+	// never rotates.
+	MaxSize int64
+	// MaxFiles is how many rotated files are kept, as Path.1 (the newest)
+	// through Path.MaxFiles. Fewer than 1 keeps 1.
+	MaxFiles int
+	// Writer receives recordings instead of Path, without rotation.
+	Writer io.Writer
+	// RedactHeaders are more headers to redact, such as "x-api-key". Names
+	// are not case sensitive.
+	RedactHeaders []string
+	// RedactFields are more JSON body fields to redact, at any depth. Names
+	// are not case sensitive.
+	RedactFields []string
+	// RecordAuthBodies records the bodies of auth tasks, which hold
+	// usernames, passwords and tokens, with RedactFields still applied.
+	RecordAuthBodies bool
+}
+
+type recorder struct {
+	options RecordingOptions
+	headers []string
+	fields  []string
+
+	mu   sync.Mutex
+	w    io.Writer
+	file *os.File
+	size int64
+}
+
+func newRecorder(options RecordingOptions) *recorder {
+	if options.Writer == nil && options.Path == "" {
+		return nil
+	}
+	if options.MaxFiles < 1 {
+		options.MaxFiles = 1
+	}
+
+	return &recorder{
+		options: options,
+		headers: append(append([]string{}, redactedHeaders...), options.RedactHeaders...),
+		fields:  append(append([]string{}, redactedFields...), options.RedactFields...),
+		w:       options.Writer,
+	}
+}
+
+// capture encodes the task as it was received, before handlers change it,
+// with the credentials its caller used.
+func (r *recorder) capture(task *Task) Recording {
+	redactedTask := *task
+	redactedTask.AuthKey = redactSecret(task.AuthKey)
+	redactedTask.AppMetadata.AppSecret = redactSecret(task.AppMetadata.AppSecret)
+	redactedTask.AppMetadata.MasterSecret = redactSecret(task.AppMetadata.MasterSecret)
+	redactedTask.Request.Headers = r.redactHeaders(task.Request.Headers)
+	redactedTask.Response.Headers = r.redactHeaders(task.Response.Headers)
+	redactedTask.Request.Body, redactedTask.Request.JSONBody = r.redactBody(task.TaskType, encodeBody(task.Request.Body, task.Request.JSONBody)), nil
+	redactedTask.Response.Body, redactedTask.Response.JSONBody = r.redactBody(task.TaskType, encodeBody(task.Response.Body, task.Response.JSONBody)), nil
+
+	data, err := EncodeTask(&redactedTask)
+	if err != nil {
+		return Recording{}
+	}
+
+	return Recording{
+		Task: data,
+		Credentials: &RecordedCredentials{
+			Type:   taskCredentialType(task),
+			UserID: task.Request.UserID,
+		},
+	}
+}
+
+func redactSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return redacted
+}
+
+// redactCredentials keeps the scheme of credentials such as "Basic ..." or
+// "Kinvey ...", so replays still see what kind of caller made the request.
+func redactCredentials(credentials string) string {
+	if i := strings.IndexByte(credentials, ' '); i > 0 {
+		return credentials[:i+1] + redacted
+	}
+	return redactSecret(credentials)
+}
+
+func (r *recorder) redactHeaders(headers map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+
+	copied := make(map[string]string, len(headers))
+	for k, v := range headers {
+		if containsFold(r.headers, k) {
+			v = redactCredentials(v)
+		}
+		copied[k] = v
+	}
+	return copied
+}
+
+// redactBody redacts the whole body of an auth task, unless RecordAuthBodies
+// is set, and the redacted fields of any other JSON body.
+func (r *recorder) redactBody(taskType string, body jsoniter.RawMessage) jsoniter.RawMessage {
+	if len(body) == 0 {
+		return body
+	}
+
+	if taskType == "auth" && !r.options.RecordAuthBodies {
+		encoded, _ := json.Marshal(redacted)
+		return encoded
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		return body
+	}
+	if !r.redactFields(decoded) {
+		return body
+	}
+
+	encoded, err := json.Marshal(decoded)
+	if err != nil {
+		return body
+	}
+	return encoded
+}
+
+// redactFields redacts the redacted fields in place, and reports whether it
+// found any.
+func (r *recorder) redactFields(value interface{}) bool {
+	found := false
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, field := range v {
+			if containsFold(r.fields, k) {
+				v[k] = redacted
+				found = true
+			} else if r.redactFields(field) {
+				found = true
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if r.redactFields(item) {
+				found = true
+			}
+		}
+	}
+
+	return found
+}
+
+func containsFold(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *recorder) record(recording Recording, taskErr *Task, result *Task) {
+	if recording.Task == nil {
+		return
+	}
+
+	if taskErr == nil {
+		recording.Response = NewRecordedResponse(result)
+		if recording.Response != nil {
+			recording.Response.Headers = r.redactHeaders(recording.Response.Headers)
+			recording.Response.Body = r.redactBody(result.TaskType, recording.Response.Body)
+		}
+	}
+
+	line, err := json.Marshal(recording)
+	if err != nil {
+		return
+	}
+
+	if err := r.write(append(line, '\n')); err != nil {
+		fmt.Println("Failed to write task recording:", err.Error())
+	}
+}
+
+func (r *recorder) write(line []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.options.Writer == nil {
+		if r.file != nil && r.options.MaxSize > 0 && r.size > 0 && r.size+int64(len(line)) > r.options.MaxSize {
+			if err := r.rotate(); err != nil {
+				return err
+			}
+		}
+
+		if r.file == nil {
+			if err := r.open(); err != nil {
+				return err
+			}
+		}
+	}
+
+	n, err := r.w.Write(line)
+	r.size += int64(n)
+	return err
+}
+
+func (r *recorder) open() error {
+	f, err := os.OpenFile(r.options.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	r.file = f
+	r.w = f
+	r.size = info.Size()
+	return nil
+}
+
+// rotate shifts Path to Path.1, Path.1 to Path.2 and so on, dropping the
+// oldest file.
+func (r *recorder) rotate() error {
+	r.file.Close()
+	r.file = nil
+	r.w = nil
+
+	path := r.options.Path
+	os.Remove(fmt.Sprintf("%s.%d", path, r.options.MaxFiles))
+	for i := r.options.MaxFiles - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", path, i), fmt.Sprintf("%s.%d", path, i+1))
+	}
+
+	return os.Rename(path, path+".1")
+}
+
+func (r *recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	r.w = nil
+	return err
+}
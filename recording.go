@@ -0,0 +1,235 @@
+package flex
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// Recording is one line of a task recording: a task in the TCP wire format
+// and the response the service gave it. A recording with no response is for
+// a task that failed or produced no result.
+type Recording struct {
+	Task     jsoniter.RawMessage `json:"task"`
+	Response *RecordedResponse   `json:"response,omitempty"`
+	// Credentials are the caller's credentials as the service classified
+	// them. Flex.Replay uses them in place of the redacted authorization
+	// header, so security contexts and policies are checked as recorded.
+	Credentials *RecordedCredentials `json:"credentials,omitempty"`
+}
+
+// RecordedCredentials ...
+type RecordedCredentials struct {
+	// Type is one of the CredentialType constants.
+	Type   string `json:"type"`
+	UserID string `json:"userId,omitempty"`
+}
+
+// RecordedResponse ...
+type RecordedResponse struct {
+	Status  int                 `json:"status"`
+	Headers map[string]string   `json:"headers,omitempty"`
+	Body    jsoniter.RawMessage `json:"body,omitempty"`
+}
+
+// NewRecordedResponse captures the response of a task result. A status the
+// handler never set is recorded as the 200 receivers send for it. Service
+// discovery results are recorded as a 200 with the discovery objects as the
+// body, which is how the HTTP receiver answers them.
+func NewRecordedResponse(result *Task) *RecordedResponse {
+	if result == nil {
+		return nil
+	}
+
+	if result.TaskType == "serviceDiscovery" {
+		body, _ := json.Marshal(result.DiscoveryObjects)
+		return &RecordedResponse{Status: 200, Body: body}
+	}
+
+	return &RecordedResponse{
+		Status:  recordedStatus(result.Response.Status),
+		Headers: result.Response.Headers,
+		Body:    encodeBody(result.Response.Body, result.Response.JSONBody),
+	}
+}
+
+// recordedStatus maps an unset status to the 200 that receivers send for it.
+func recordedStatus(status int) int {
+	if status == 0 {
+		return 200
+	}
+	return status
+}
+
+// ReadRecordings reads a JSONL task recording. Blank lines are skipped.
+func ReadRecordings(r io.Reader) ([]Recording, error) {
+	var recordings []Recording
+
+	buf := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		data, err := buf.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		if data = bytes.TrimSpace(data); len(data) > 0 {
+			recording := Recording{}
+			if jsonErr := json.Unmarshal(data, &recording); jsonErr != nil {
+				return nil, fmt.Errorf("Error parsing recording on line %d: %v", line, jsonErr)
+			}
+			if len(recording.Task) == 0 {
+				return nil, fmt.Errorf("Recording on line %d has no task", line)
+			}
+			recordings = append(recordings, recording)
+		}
+
+		if err == io.EOF {
+			return recordings, nil
+		}
+	}
+}
+
+// DiffResponses compares an actual response to the expected one and
+// describes each difference. Bodies are compared as JSON, so key order and
+// formatting do not matter, and a status of 0 is the same as 200. Redacted
+// values, and paths such as "headers" or "body._kmd.lmt" in ignore and
+// everything beneath them, are left out of the comparison.
+func DiffResponses(expected *RecordedResponse, actual *RecordedResponse, ignore ...string) []string {
+	var diffs []string
+
+	switch {
+	case expected == nil && actual == nil:
+		return nil
+	case expected == nil:
+		return []string{"response: expected none, got status " + fmt.Sprint(actual.Status)}
+	case actual == nil:
+		return []string{"response: expected status " + fmt.Sprint(expected.Status) + ", got none"}
+	}
+
+	// recordings made before unset statuses were recorded as 200 hold a 0
+	expectedStatus, actualStatus := recordedStatus(expected.Status), recordedStatus(actual.Status)
+	if expectedStatus != actualStatus && !ignored("status", ignore) {
+		diffs = append(diffs, fmt.Sprintf("status: expected %d, got %d", expectedStatus, actualStatus))
+	}
+
+	expectedHeaders := make(map[string]interface{}, len(expected.Headers))
+	for k, v := range expected.Headers {
+		expectedHeaders[k] = v
+	}
+	actualHeaders := make(map[string]interface{}, len(actual.Headers))
+	for k, v := range actual.Headers {
+		actualHeaders[k] = v
+	}
+	diffValues("headers", expectedHeaders, actualHeaders, ignore, &diffs)
+
+	expectedBody, expectedOK := decodeRecordedBody(expected.Body)
+	actualBody, actualOK := decodeRecordedBody(actual.Body)
+	if expectedOK && actualOK {
+		diffValues("body", expectedBody, actualBody, ignore, &diffs)
+	} else if !bytes.Equal(bytes.TrimSpace(expected.Body), bytes.TrimSpace(actual.Body)) && !ignored("body", ignore) {
+		diffs = append(diffs, fmt.Sprintf("body: expected %s, got %s", expected.Body, actual.Body))
+	}
+
+	return diffs
+}
+
+func decodeRecordedBody(raw jsoniter.RawMessage) (interface{}, bool) {
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return nil, true
+	}
+
+	var body interface{}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return nil, false
+	}
+	return body, true
+}
+
+func ignored(path string, ignore []string) bool {
+	for _, prefix := range ignore {
+		if path == prefix || strings.HasPrefix(path, prefix+".") || strings.HasPrefix(path, prefix+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+func diffValues(path string, expected interface{}, actual interface{}, ignore []string, diffs *[]string) {
+	// values redacted when they were recorded cannot be compared
+	if ignored(path, ignore) || isRedacted(expected) {
+		return
+	}
+
+	expectedMap, expectedIsMap := expected.(map[string]interface{})
+	actualMap, actualIsMap := actual.(map[string]interface{})
+	if expectedIsMap && actualIsMap {
+		keys := make([]string, 0, len(expectedMap)+len(actualMap))
+		for k := range expectedMap {
+			keys = append(keys, k)
+		}
+		for k := range actualMap {
+			if _, ok := expectedMap[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			keyPath := path + "." + k
+			e, inExpected := expectedMap[k]
+			a, inActual := actualMap[k]
+
+			switch {
+			case !inActual:
+				if !ignored(keyPath, ignore) {
+					*diffs = append(*diffs, fmt.Sprintf("%s: missing, expected %s", keyPath, diffString(e)))
+				}
+			case !inExpected:
+				if !ignored(keyPath, ignore) {
+					*diffs = append(*diffs, fmt.Sprintf("%s: unexpected %s", keyPath, diffString(a)))
+				}
+			default:
+				diffValues(keyPath, e, a, ignore, diffs)
+			}
+		}
+		return
+	}
+
+	expectedSlice, expectedIsSlice := expected.([]interface{})
+	actualSlice, actualIsSlice := actual.([]interface{})
+	if expectedIsSlice && actualIsSlice {
+		if len(expectedSlice) != len(actualSlice) {
+			*diffs = append(*diffs, fmt.Sprintf("%s: expected %d items, got %d", path, len(expectedSlice), len(actualSlice)))
+		}
+
+		for i := 0; i < len(expectedSlice) && i < len(actualSlice); i++ {
+			diffValues(fmt.Sprintf("%s[%d]", path, i), expectedSlice[i], actualSlice[i], ignore, diffs)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		*diffs = append(*diffs, fmt.Sprintf("%s: expected %s, got %s", path, diffString(expected), diffString(actual)))
+	}
+}
+
+// isRedacted reports whether a recorded value is redacted, either entirely or
+// after a credentials scheme such as "Basic".
+func isRedacted(v interface{}) bool {
+	s, ok := v.(string)
+	return ok && (s == redacted || strings.HasSuffix(s, " "+redacted))
+}
+
+func diffString(v interface{}) string {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(encoded)
+}
@@ -0,0 +1,129 @@
+package flex
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffResponses(t *testing.T) {
+	expected := &RecordedResponse{
+		Status:  200,
+		Headers: map[string]string{"x-kinvey-api-version": "4"},
+		Body:    []byte(`{"name":"widget","tags":["a","b"],"_kmd":{"lmt":"2024-01-01"}}`),
+	}
+
+	same := &RecordedResponse{
+		Status:  200,
+		Headers: map[string]string{"x-kinvey-api-version": "4"},
+		Body:    []byte(`{ "_kmd": {"lmt": "2024-01-01"}, "tags": ["a", "b"], "name": "widget" }`),
+	}
+	if diffs := DiffResponses(expected, same); diffs != nil {
+		t.Fatalf("expected no differences, got %v", diffs)
+	}
+
+	changed := &RecordedResponse{
+		Status: 201,
+		Body:   []byte(`{"name":"gadget","tags":["a"],"_kmd":{"lmt":"2025-01-01"},"extra":true}`),
+	}
+	want := []string{
+		"status: expected 200, got 201",
+		`headers.x-kinvey-api-version: missing, expected "4"`,
+		`body._kmd.lmt: expected "2024-01-01", got "2025-01-01"`,
+		"body.extra: unexpected true",
+		`body.name: expected "widget", got "gadget"`,
+		"body.tags: expected 2 items, got 1",
+	}
+	if diffs := DiffResponses(expected, changed); !reflect.DeepEqual(diffs, want) {
+		t.Fatalf("unexpected differences:\n%s", strings.Join(diffs, "\n"))
+	}
+
+	want = []string{
+		"status: expected 200, got 201",
+		`body.name: expected "widget", got "gadget"`,
+		"body.tags: expected 2 items, got 1",
+	}
+	if diffs := DiffResponses(expected, changed, "headers", "body._kmd", "body.extra"); !reflect.DeepEqual(diffs, want) {
+		t.Fatalf("unexpected differences with ignore:\n%s", strings.Join(diffs, "\n"))
+	}
+
+	// recordings made before unset statuses were recorded as 200 hold a 0
+	if diffs := DiffResponses(&RecordedResponse{Status: 0}, &RecordedResponse{Status: 200}); diffs != nil {
+		t.Fatalf("expected status 0 to match 200, got %v", diffs)
+	}
+
+	if diffs := DiffResponses(expected, nil); len(diffs) != 1 {
+		t.Fatalf("expected a missing response to differ, got %v", diffs)
+	}
+}
+
+func TestReadRecordings(t *testing.T) {
+	recordings, err := ReadRecordings(strings.NewReader(`{"task":{"taskId":"t1"},"response":{"status":200}}
+
+{"task":{"taskId":"t2"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recordings) != 2 || recordings[0].Response.Status != 200 || recordings[1].Response != nil {
+		t.Fatalf("unexpected recordings %+v", recordings)
+	}
+
+	_, err = ReadRecordings(strings.NewReader("{\"task\":{}}\nnot json\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("expected a parse error on line 2, got %v", err)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	s := newFlex(NewOptions("", 10001, "secret"))
+
+	widgets := s.Data.NewServiceObject("widgets")
+	widgets.OnGetByID(func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task) {
+		return complete.SetBody([]byte(`{"_id":"` + context.EntityID + `","name":"widget"}`)).OK().Done()
+	})
+
+	gadgets := s.Data.NewServiceObject("gadgets")
+	gadgets.OnGetByID(func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task) {
+		errTask := complete.SetBody([]byte(`{"error":"unavailable"}`)).Task
+		return errTask, nil
+	})
+
+	// recorded auth keys are redacted
+	task := `{"taskId":"t1","taskType":"data","method":"GET","authKey":"[REDACTED]","request":{"method":"GET","serviceObjectName":"widgets","entityId":"w1"}}`
+	failing := `{"taskId":"t2","taskType":"data","method":"GET","request":{"method":"GET","serviceObjectName":"gadgets","entityId":"g1"}}`
+
+	recordings := []Recording{
+		{Task: []byte(task), Response: &RecordedResponse{Status: 200, Body: []byte(`{"_id":"w1","name":"widget"}`)}},
+		{Task: []byte(task), Response: &RecordedResponse{Status: 200, Body: []byte(`{"_id":"w1","name":"gadget"}`)}},
+		{Task: []byte(`not json`)},
+		{Task: []byte(failing)},
+		{Task: []byte(failing), Response: &RecordedResponse{Status: 200}},
+	}
+
+	results := s.Replay(recordings)
+	if len(results) != 5 {
+		t.Fatalf("expected 5 results, got %d", len(results))
+	}
+
+	if results[0].Failed() || results[0].TaskID != "t1" {
+		t.Fatalf("expected the first task to match, got %s", results[0])
+	}
+
+	if !results[1].Failed() || !reflect.DeepEqual(results[1].Differences, []string{`body.name: expected "gadget", got "widget"`}) {
+		t.Fatalf("expected the second task to mismatch on the name, got %s", results[1])
+	}
+
+	if results[2].Err == nil {
+		t.Fatalf("expected an unparseable task to fail, got %s", results[2])
+	}
+
+	// a task that failed when it was recorded is expected to fail again, but
+	// is reported apart from the tasks that matched
+	if results[3].Failed() || !results[3].FailedAsRecorded() || !strings.Contains(results[3].String(), "failed as recorded") {
+		t.Fatalf("expected the fourth task to fail as recorded, got %s", results[3])
+	}
+
+	if !results[4].Failed() || results[4].FailedAsRecorded() {
+		t.Fatalf("expected a task recorded with a response to fail, got %s", results[4])
+	}
+}
@@ -0,0 +1,105 @@
+package flex
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ReplayResult is the outcome of replaying one recorded task.
+type ReplayResult struct {
+	// Index is the recording's position in the replayed recordings.
+	Index       int
+	TaskID      string
+	Expected    *RecordedResponse
+	Actual      *RecordedResponse
+	Differences []string
+	// Err is set when the task failed. A task recorded without a response
+	// failed when it was recorded too, so failing again is expected.
+	Err error
+}
+
+// Failed reports whether the response did not match, or the task failed when
+// it was recorded with a response.
+func (r ReplayResult) Failed() bool {
+	return len(r.Differences) > 0 || (r.Err != nil && r.Expected != nil)
+}
+
+// FailedAsRecorded reports whether the task failed, as it did when it was
+// recorded.
+func (r ReplayResult) FailedAsRecorded() bool {
+	return r.Err != nil && r.Expected == nil
+}
+
+// String ...
+func (r ReplayResult) String() string {
+	name := fmt.Sprintf("#%d", r.Index+1)
+	if r.TaskID != "" {
+		name += " (" + r.TaskID + ")"
+	}
+
+	if r.FailedAsRecorded() {
+		return name + ": failed as recorded: " + r.Err.Error()
+	}
+	if r.Err != nil {
+		return name + ": " + r.Err.Error()
+	}
+	if len(r.Differences) == 0 {
+		return name + ": ok"
+	}
+	return name + ":\n    " + strings.Join(r.Differences, "\n    ")
+}
+
+// Replay runs recorded tasks through the service's dispatch, one at a time
+// and in order, as if a receiver had received them, and compares each
+// response to the recorded one. Recorded auth keys are replaced with the
+// service's shared secret, and the recorded credentials stand in for the
+// authorization header, since recordings have them redacted. See
+// DiffResponses for ignore.
+func (s Flex) Replay(recordings []Recording, ignore ...string) []ReplayResult {
+	results := make([]ReplayResult, 0, len(recordings))
+
+	for i, recording := range recordings {
+		result := ReplayResult{
+			Index:    i,
+			Expected: recording.Response,
+		}
+
+		task, err := DecodeTask(recording.Task)
+		if err != nil {
+			result.Err = err
+			results = append(results, result)
+			continue
+		}
+		result.TaskID = task.TaskID
+		task.AuthKey = s.sharedSecret
+		if recording.Credentials != nil {
+			task.credentialType = recording.Credentials.Type
+			task.Request.UserID = recording.Credentials.UserID
+		}
+
+		result.Actual, result.Err = s.replayTask(task)
+		if result.Err == nil {
+			result.Differences = DiffResponses(result.Expected, result.Actual, ignore...)
+		}
+
+		results = append(results, result)
+	}
+
+	return results
+}
+
+func (s Flex) replayTask(task *Task) (actual *RecordedResponse, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("Task failed: %v", r)
+		}
+	}()
+
+	taskErr, result := s.dispatch(task)
+	if taskErr != nil {
+		return nil, errors.New("Task failed: " + string(taskErr.Response.Body))
+	}
+
+	return NewRecordedResponse(result), nil
+}
@@ -0,0 +1,54 @@
+// +build !js,!wasm
+
+package flex
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// replayReceiver runs a task recording through the service in-process and
+// reports how each response compares to the recorded one.
+type replayReceiver struct {
+	path   string
+	ignore []string
+}
+
+func (rec *replayReceiver) Start(flex Flex, taskReceivedCallback func(task *Task) (*Task, *Task), options string) error {
+	if rec.path == "" {
+		return errors.New("SDK_REPLAY_FILE must name a task recording to replay")
+	}
+
+	f, err := os.Open(rec.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	recordings, err := ReadRecordings(f)
+	if err != nil {
+		return err
+	}
+
+	failed, failedAsRecorded := 0, 0
+	for _, result := range flex.Replay(recordings, rec.ignore...) {
+		if result.Failed() {
+			failed++
+		} else if result.FailedAsRecorded() {
+			failedAsRecorded++
+		}
+		fmt.Println(result.String())
+	}
+
+	fmt.Println(fmt.Sprintf("Replayed %d tasks, %d mismatched, %d failed as recorded", len(recordings), failed, failedAsRecorded))
+
+	if failed > 0 {
+		return errors.New("Replay found mismatched responses")
+	}
+	return nil
+}
+
+func (rec *replayReceiver) Stop() error {
+	return nil
+}
@@ -0,0 +1,69 @@
+package flex
+
+// RequestContextModule describes who made the request being handled.
+type RequestContextModule struct {
+	requestMetadata RequestMetadata
+}
+
+func newRequestContextModule(requestMetadata RequestMetadata) RequestContextModule {
+	return RequestContextModule{
+		requestMetadata: requestMetadata,
+	}
+}
+
+// GetAuthenticatedUserID ...
+func (m RequestContextModule) GetAuthenticatedUserID() string {
+	return m.requestMetadata.AuthenticatedUserID
+}
+
+// GetAuthenticatedUsername ...
+func (m RequestContextModule) GetAuthenticatedUsername() string {
+	return m.requestMetadata.AuthenticatedUsername
+}
+
+// GetSecurityContext returns one of the SecurityContext constants.
+func (m RequestContextModule) GetSecurityContext() string {
+	return m.requestMetadata.SecurityContext
+}
+
+// GetCredentialType returns one of the CredentialType constants.
+func (m RequestContextModule) GetCredentialType() string {
+	return m.requestMetadata.CredentialType
+}
+
+// GetClientAppVersion ...
+func (m RequestContextModule) GetClientAppVersion() string {
+	return m.requestMetadata.ClientAppVersion
+}
+
+// GetClientIP returns the address of the original caller: the rightmost entry
+// of the X-Forwarded-For header, which Kinvey appended. Entries to its left
+// are set by the caller and are not used.
+func (m RequestContextModule) GetClientIP() string {
+	return m.requestMetadata.ClientIP
+}
+
+// GetCustomRequestProperties ...
+func (m RequestContextModule) GetCustomRequestProperties() map[string]interface{} {
+	return m.requestMetadata.CustomRequestProperties
+}
+
+// GetRequestID ...
+func (m RequestContextModule) GetRequestID() string {
+	return m.requestMetadata.RequestID
+}
+
+// IsMaster ...
+func (m RequestContextModule) IsMaster() bool {
+	return m.requestMetadata.SecurityContext == SecurityContextMaster
+}
+
+// IsApp ...
+func (m RequestContextModule) IsApp() bool {
+	return m.requestMetadata.SecurityContext == SecurityContextApp
+}
+
+// IsUser ...
+func (m RequestContextModule) IsUser() bool {
+	return m.requestMetadata.SecurityContext == SecurityContextUser
+}
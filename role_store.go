@@ -13,13 +13,15 @@ type RoleStoreModule struct {
 	appMetadata    kinveyAppMetadata
 	requestContext RequestMetadata
 	taskMetadata   TaskMetadata
+	baas           *baasClient
 }
 
-func newRoleStoreModule(appMetadata kinveyAppMetadata, requestMetadata RequestMetadata, taskMetadata TaskMetadata) RoleStoreModule {
+func newRoleStoreModule(appMetadata kinveyAppMetadata, requestMetadata RequestMetadata, taskMetadata TaskMetadata, baas *baasClient) RoleStoreModule {
 	return RoleStoreModule{
 		appMetadata:    appMetadata,
 		requestContext: requestMetadata,
 		taskMetadata:   taskMetadata,
+		baas:           baas,
 	}
 }
 
@@ -33,7 +35,7 @@ func (m RoleStoreModule) NewRoleStore(useUserContext bool) RoleStore {
 	s.taskMetadata = m.taskMetadata
 	s.useBL = true
 	s.useUserContext = useUserContext
-	s.client = &http.Client{}
+	s.baas = m.baas
 	return s
 }
 
@@ -50,8 +52,11 @@ type Role struct {
 	Description string `json:"description,omitempty"`
 }
 
+// buildRoleRequest builds a request to /roles/:appKey/. Roles are addressed
+// directly beneath the app, as /roles/:appKey/:roleId and
+// /roles/:appKey/:roleId/membership.
 func (s RoleStore) buildRoleRequest() (*http.Request, error) {
-	return s.buildKinveyRequest(s.baseRoute, "custom", false, s.useUserContext)
+	return s.buildKinveyRequest(s.baseRoute, "", false, s.useUserContext)
 }
 
 func (s RoleStore) makeRoleRequest(req *http.Request) ([]byte, error) {
@@ -88,7 +93,7 @@ func (s RoleStore) Update(role Role) ([]byte, error) {
 
 	requestOptions.Method = "PUT"
 
-	u, err := url.Parse(requestOptions.URL.String() + role.ID)
+	u, err := url.Parse(requestOptions.URL.String() + url.PathEscape(role.ID))
 	if err != nil {
 		return nil, err
 	}
@@ -115,7 +120,7 @@ func (s RoleStore) FindByID(id string) ([]byte, error) {
 
 	requestOptions.Method = "GET"
 
-	u, err := url.Parse(requestOptions.URL.String() + id)
+	u, err := url.Parse(requestOptions.URL.String() + url.PathEscape(id))
 	if err != nil {
 		return nil, err
 	}
@@ -138,7 +143,7 @@ func (s RoleStore) Remove(id string) ([]byte, error) {
 
 	requestOptions.Method = "DELETE"
 
-	u, err := url.Parse(requestOptions.URL.String() + id)
+	u, err := url.Parse(requestOptions.URL.String() + url.PathEscape(id))
 	if err != nil {
 		return nil, err
 	}
@@ -161,7 +166,7 @@ func (s RoleStore) ListMembers(id string) ([]byte, error) {
 
 	requestOptions.Method = "GET"
 
-	u, err := url.Parse(requestOptions.URL.String() + id + "/membership")
+	u, err := url.Parse(requestOptions.URL.String() + url.PathEscape(id) + "/membership")
 	if err != nil {
 		return nil, err
 	}
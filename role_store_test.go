@@ -0,0 +1,44 @@
+package flex
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRoleStorePaths(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.EscapedPath())
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	roles := RoleStore{baseRoute: "roles"}
+	roles.appMetadata = kinveyAppMetadata{ID: "kid_abc123", BaaSURL: server.URL}
+	roles.baas = testBaaSClient(RetryPolicy{}, CircuitBreakerPolicy{})
+
+	roles.Create(Role{Name: "admins"})
+	roles.Update(Role{ID: "r1", Name: "admins"})
+	roles.FindByID("r1")
+	roles.ListMembers("r1")
+	roles.Remove("r1")
+	roles.FindByID("a/b?c")
+
+	want := []string{
+		"POST /roles/kid_abc123/",
+		"PUT /roles/kid_abc123/r1",
+		"GET /roles/kid_abc123/r1",
+		"GET /roles/kid_abc123/r1/membership",
+		"DELETE /roles/kid_abc123/r1",
+		"GET /roles/kid_abc123/a%2Fb%3Fc",
+	}
+	if len(paths) != len(want) {
+		t.Fatalf("expected %v, got %v", want, paths)
+	}
+	for i := range want {
+		if paths[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, paths)
+		}
+	}
+}
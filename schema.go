@@ -0,0 +1,228 @@
+package flex
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Schema is a JSON Schema used to validate request bodies. Only the keywords
+// below are supported; anything else in the document is ignored. Schemas can
+// be parsed with NewSchema or written as literals.
+type Schema struct {
+	Type                 string             `json:"type,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+}
+
+// patterns caches compiled Pattern values, so schemas built as literals are
+// checked the same as those from NewSchema without compiling on every request.
+var patterns sync.Map
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if p, ok := patterns.Load(pattern); ok {
+		return p.(*regexp.Regexp), nil
+	}
+
+	p, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	patterns.Store(pattern, p)
+	return p, nil
+}
+
+// NewSchema ...
+func NewSchema(document []byte) (*Schema, error) {
+	s := &Schema{}
+
+	err := json.Unmarshal(document, s)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.compile()
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *Schema) compile() error {
+	switch s.Type {
+	case "", "object", "array", "string", "number", "integer", "boolean", "null":
+	default:
+		return errors.New("Unsupported schema type " + s.Type)
+	}
+
+	if s.Pattern != "" {
+		_, err := compilePattern(s.Pattern)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, p := range s.Properties {
+		err := p.compile()
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.Items != nil {
+		return s.Items.compile()
+	}
+
+	return nil
+}
+
+// Validate checks body against the schema and returns every violation found.
+// An empty result means the body is valid.
+func (s *Schema) Validate(body []byte) []string {
+	var document interface{}
+
+	err := json.Unmarshal(body, &document)
+	if err != nil {
+		return []string{"body is not valid JSON"}
+	}
+
+	return s.validate("$", document, make([]string, 0))
+}
+
+func (s *Schema) validate(path string, value interface{}, violations []string) []string {
+	if len(s.Enum) > 0 && !s.inEnum(value) {
+		violations = append(violations, fmt.Sprintf("%s: must be one of the enumerated values", path))
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if !s.allows("object") {
+			return append(violations, fmt.Sprintf("%s: must be of type %s", path, s.Type))
+		}
+
+		for _, r := range s.Required {
+			if _, ok := v[r]; !ok {
+				violations = append(violations, fmt.Sprintf("%s.%s: is required", path, r))
+			}
+		}
+
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			if p, ok := s.Properties[key]; ok {
+				violations = p.validate(path+"."+key, v[key], violations)
+			} else if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				violations = append(violations, fmt.Sprintf("%s.%s: is not allowed", path, key))
+			}
+		}
+	case []interface{}:
+		if !s.allows("array") {
+			return append(violations, fmt.Sprintf("%s: must be of type %s", path, s.Type))
+		}
+
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			violations = append(violations, fmt.Sprintf("%s: must have at least %d items", path, *s.MinItems))
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			violations = append(violations, fmt.Sprintf("%s: must have at most %d items", path, *s.MaxItems))
+		}
+
+		if s.Items != nil {
+			for i := range v {
+				violations = s.Items.validate(fmt.Sprintf("%s[%d]", path, i), v[i], violations)
+			}
+		}
+	case string:
+		if !s.allows("string") {
+			return append(violations, fmt.Sprintf("%s: must be of type %s", path, s.Type))
+		}
+
+		length := len([]rune(v))
+		if s.MinLength != nil && length < *s.MinLength {
+			violations = append(violations, fmt.Sprintf("%s: must be at least %d characters", path, *s.MinLength))
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			violations = append(violations, fmt.Sprintf("%s: must be at most %d characters", path, *s.MaxLength))
+		}
+		if s.Pattern != "" {
+			p, err := compilePattern(s.Pattern)
+			if err != nil {
+				violations = append(violations, fmt.Sprintf("%s: schema pattern %s is invalid", path, s.Pattern))
+			} else if !p.MatchString(v) {
+				violations = append(violations, fmt.Sprintf("%s: must match pattern %s", path, s.Pattern))
+			}
+		}
+	case float64:
+		if !s.allows("number") && !(s.Type == "integer" && v == math.Trunc(v)) {
+			return append(violations, fmt.Sprintf("%s: must be of type %s", path, s.Type))
+		}
+
+		if s.Minimum != nil && v < *s.Minimum {
+			violations = append(violations, fmt.Sprintf("%s: must be at least %v", path, *s.Minimum))
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			violations = append(violations, fmt.Sprintf("%s: must be at most %v", path, *s.Maximum))
+		}
+	case bool:
+		if !s.allows("boolean") {
+			return append(violations, fmt.Sprintf("%s: must be of type %s", path, s.Type))
+		}
+	case nil:
+		if !s.allows("null") {
+			return append(violations, fmt.Sprintf("%s: must be of type %s", path, s.Type))
+		}
+	}
+
+	return violations
+}
+
+func (s *Schema) allows(jsonType string) bool {
+	return s.Type == "" || s.Type == jsonType
+}
+
+func (s *Schema) inEnum(value interface{}) bool {
+	for _, e := range s.Enum {
+		if reflect.DeepEqual(e, value) {
+			return true
+		}
+	}
+	return false
+}
+
+type validationError struct {
+	KinveyError
+	Violations []string `json:"violations"`
+}
+
+func newValidationError(violations []string) validationError {
+	return validationError{
+		KinveyError: KinveyError{
+			Error:       "ValidationError",
+			Description: "The request body failed validation",
+			Debug:       strings.Join(violations, "; "),
+			StatusCode:  400,
+		},
+		Violations: violations,
+	}
+}
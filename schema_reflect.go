@@ -0,0 +1,100 @@
+package flex
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// SchemaFor describes T as a JSON Schema, following its json struct tags.
+// A `description` struct tag becomes the property's description. The result
+// is meant for documentation; fields are not marked as required.
+func SchemaFor[T any]() *Schema {
+	return schemaForType(reflect.TypeOf((*T)(nil)).Elem(), make(map[reflect.Type]bool))
+}
+
+func schemaForType(t reflect.Type, visiting map[reflect.Type]bool) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == timeType {
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// encoding/json writes byte slices as base64 strings
+			return &Schema{Type: "string"}
+		}
+		return &Schema{Type: "array", Items: schemaForType(t.Elem(), visiting)}
+	case reflect.Map:
+		return &Schema{Type: "object"}
+	case reflect.Struct:
+		s := &Schema{Type: "object"}
+		// recursive types are described once; inner references stay open
+		if visiting[t] {
+			return s
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		s.Properties = make(map[string]*Schema)
+		addStructProperties(s, t, visiting)
+		return s
+	}
+
+	return &Schema{}
+}
+
+func addStructProperties(s *Schema, t reflect.Type, visiting map[reflect.Type]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name := strings.Split(tag, ",")[0]
+
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+
+		// untagged embedded structs are flattened, as encoding/json does
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			if !visiting[fieldType] {
+				visiting[fieldType] = true
+				addStructProperties(s, fieldType, visiting)
+				delete(visiting, fieldType)
+			}
+			continue
+		}
+
+		if field.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		property := schemaForType(field.Type, visiting)
+		property.Description = field.Tag.Get("description")
+		s.Properties[name] = property
+	}
+}
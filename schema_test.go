@@ -0,0 +1,83 @@
+package flex
+
+import (
+	"reflect"
+	"testing"
+)
+
+func intPtr(i int) *int { return &i }
+
+func floatPtr(f float64) *float64 { return &f }
+
+func boolPtr(b bool) *bool { return &b }
+
+func TestSchemaValidate(t *testing.T) {
+	cases := []struct {
+		name   string
+		schema *Schema
+		body   string
+		want   []string
+	}{
+		{"invalid JSON", &Schema{}, `{`, []string{"body is not valid JSON"}},
+		{"type object", &Schema{Type: "object"}, `[]`, []string{"$: must be of type object"}},
+		{"type array", &Schema{Type: "array"}, `{}`, []string{"$: must be of type array"}},
+		{"type string", &Schema{Type: "string"}, `1`, []string{"$: must be of type string"}},
+		{"type number", &Schema{Type: "number"}, `"1"`, []string{"$: must be of type number"}},
+		{"type integer", &Schema{Type: "integer"}, `1.5`, []string{"$: must be of type integer"}},
+		{"type integer whole", &Schema{Type: "integer"}, `2`, []string{}},
+		{"type boolean", &Schema{Type: "boolean"}, `null`, []string{"$: must be of type boolean"}},
+		{"type null", &Schema{Type: "null"}, `false`, []string{"$: must be of type null"}},
+		{"required", &Schema{Type: "object", Required: []string{"name", "size"}}, `{"name":"a"}`, []string{"$.size: is required"}},
+		{"properties", &Schema{Properties: map[string]*Schema{"size": {Type: "integer"}}}, `{"size":"big"}`, []string{"$.size: must be of type integer"}},
+		{"additionalProperties", &Schema{Properties: map[string]*Schema{"name": {}}, AdditionalProperties: boolPtr(false)}, `{"name":"a","color":"red"}`, []string{"$.color: is not allowed"}},
+		{"additionalProperties allowed", &Schema{Properties: map[string]*Schema{"name": {}}}, `{"name":"a","color":"red"}`, []string{}},
+		{"items", &Schema{Items: &Schema{Type: "string"}}, `["a",1]`, []string{"$[1]: must be of type string"}},
+		{"minItems", &Schema{MinItems: intPtr(2)}, `[1]`, []string{"$: must have at least 2 items"}},
+		{"maxItems", &Schema{MaxItems: intPtr(1)}, `[1,2]`, []string{"$: must have at most 1 items"}},
+		{"minLength", &Schema{MinLength: intPtr(3)}, `"ab"`, []string{"$: must be at least 3 characters"}},
+		{"maxLength counts runes", &Schema{MaxLength: intPtr(2)}, `"éé"`, []string{}},
+		{"maxLength", &Schema{MaxLength: intPtr(2)}, `"abc"`, []string{"$: must be at most 2 characters"}},
+		{"pattern", &Schema{Pattern: "^a"}, `"ba"`, []string{"$: must match pattern ^a"}},
+		{"pattern matches", &Schema{Pattern: "^a"}, `"ab"`, []string{}},
+		{"invalid pattern", &Schema{Pattern: "("}, `"a"`, []string{"$: schema pattern ( is invalid"}},
+		{"minimum", &Schema{Minimum: floatPtr(1)}, `0.5`, []string{"$: must be at least 1"}},
+		{"maximum", &Schema{Maximum: floatPtr(1)}, `2`, []string{"$: must be at most 1"}},
+		{"enum", &Schema{Enum: []interface{}{"red", float64(1)}}, `"blue"`, []string{"$: must be one of the enumerated values"}},
+		{"enum matches", &Schema{Enum: []interface{}{"red", float64(1)}}, `1`, []string{}},
+		{"nested", &Schema{Properties: map[string]*Schema{"tags": {Items: &Schema{Properties: map[string]*Schema{"name": {MinLength: intPtr(1)}}}}}}, `{"tags":[{"name":""}]}`, []string{"$.tags[0].name: must be at least 1 characters"}},
+	}
+
+	for _, c := range cases {
+		got := c.schema.Validate([]byte(c.body))
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestNewSchema(t *testing.T) {
+	s, err := NewSchema([]byte(`{"type":"object","properties":{"name":{"type":"string","pattern":"^[a-z]+$"}},"required":["name"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if violations := s.Validate([]byte(`{"name":"Widget"}`)); len(violations) != 1 {
+		t.Fatalf("expected the pattern to apply, got %q", violations)
+	}
+
+	for _, document := range []string{`{"type":"widget"}`, `{"pattern":"("}`, `{"items":{"type":"thing"}}`, `not json`} {
+		if _, err := NewSchema([]byte(document)); err == nil {
+			t.Errorf("expected %s to be rejected", document)
+		}
+	}
+}
+
+func TestSetSchemaRejectsUnknownOperations(t *testing.T) {
+	so := newData(newMiddlewareStack()).NewServiceObject("widgets")
+
+	if err := so.SetSchema("onInsert", &Schema{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := so.SetSchema("onGetByld", &Schema{}); err == nil {
+		t.Fatal("expected a misspelled operation to be rejected")
+	}
+}
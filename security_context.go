@@ -0,0 +1,118 @@
+package flex
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"strings"
+)
+
+// Security contexts reported by RequestContextModule.GetSecurityContext.
+const (
+	SecurityContextUnknown = "unknown"
+	SecurityContextUser    = "user"
+	SecurityContextApp     = "app"
+	SecurityContextMaster  = "master"
+)
+
+// Credential types reported by RequestContextModule.GetCredentialType. They
+// refine the security context by telling apart the two ways a user can
+// authenticate.
+const (
+	CredentialTypeUnknown      = "unknown"
+	CredentialTypeUserSession  = "userSession"
+	CredentialTypeUserBasic    = "userBasic"
+	CredentialTypeAppSecret    = "appSecret"
+	CredentialTypeMasterSecret = "masterSecret"
+)
+
+// classifyCredentials works out which kind of credentials an Authorization
+// header carries. Basic credentials are the app key with either the app
+// secret or the master secret, or a username and password; Kinvey
+// credentials are a user session token.
+func classifyCredentials(authorizationHeader string, appMetadata kinveyAppMetadata) string {
+	parts := strings.SplitN(strings.TrimSpace(authorizationHeader), " ", 2)
+	if len(parts) != 2 {
+		return CredentialTypeUnknown
+	}
+
+	scheme := parts[0]
+	value := strings.TrimSpace(parts[1])
+	if value == "" {
+		return CredentialTypeUnknown
+	}
+
+	if strings.EqualFold(scheme, "Kinvey") {
+		return CredentialTypeUserSession
+	}
+
+	if !strings.EqualFold(scheme, "Basic") {
+		return CredentialTypeUnknown
+	}
+
+	decodedCredentials, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return CredentialTypeUnknown
+	}
+
+	credentials := strings.SplitN(string(decodedCredentials), ":", 2)
+	if len(credentials) != 2 || credentials[0] == "" {
+		return CredentialTypeUnknown
+	}
+
+	if credentials[0] != appMetadata.ID {
+		return CredentialTypeUserBasic
+	}
+
+	if secretEquals(credentials[1], appMetadata.MasterSecret) {
+		return CredentialTypeMasterSecret
+	}
+	if secretEquals(credentials[1], appMetadata.AppSecret) {
+		return CredentialTypeAppSecret
+	}
+
+	return CredentialTypeUnknown
+}
+
+func secretEquals(given string, secret string) bool {
+	return secret != "" && subtle.ConstantTimeCompare([]byte(given), []byte(secret)) == 1
+}
+
+// taskCredentialType is the credential type of the task's caller.
+func taskCredentialType(task *Task) string {
+	if task.credentialType != "" {
+		return task.credentialType
+	}
+	return classifyCredentials(task.Request.Headers["authorization"], task.AppMetadata)
+}
+
+func securityContextFor(credentialType string) string {
+	switch credentialType {
+	case CredentialTypeUserSession, CredentialTypeUserBasic:
+		return SecurityContextUser
+	case CredentialTypeAppSecret:
+		return SecurityContextApp
+	case CredentialTypeMasterSecret:
+		return SecurityContextMaster
+	}
+	return SecurityContextUnknown
+}
+
+// RequireSecurityContext returns middleware that only lets requests made
+// with one of the given security contexts through, e.g.
+// RequireSecurityContext(flex.SecurityContextMaster). Anything else is
+// rejected with a 401 InsufficientCredentials error.
+func RequireSecurityContext(securityContexts ...string) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task) {
+			securityContext := modules.RequestContext().GetSecurityContext()
+
+			for _, allowed := range securityContexts {
+				if securityContext == allowed {
+					return next(context, complete, modules)
+				}
+			}
+
+			return complete.InsufficientCredentials("Requests with a " + securityContext + " security context are not allowed").Done()
+		}
+	}
+}
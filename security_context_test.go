@@ -0,0 +1,133 @@
+package flex
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func basic(credentials string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
+}
+
+func TestClassifyCredentials(t *testing.T) {
+	appMetadata := kinveyAppMetadata{
+		ID:           "kid_abc123",
+		AppSecret:    "appsecret",
+		MasterSecret: "mastersecret",
+	}
+
+	tests := []struct {
+		authorization string
+		want          string
+	}{
+		{"", CredentialTypeUnknown},
+		{"Basic", CredentialTypeUnknown},
+		{"Basic !!!", CredentialTypeUnknown},
+		{basic("no-colon"), CredentialTypeUnknown},
+		{basic(":password"), CredentialTypeUnknown},
+		{basic("kid_abc123:appsecret"), CredentialTypeAppSecret},
+		{basic("kid_abc123:mastersecret"), CredentialTypeMasterSecret},
+		{basic("kid_abc123:kid_abc123"), CredentialTypeUnknown},
+		{basic("kid_abc123:"), CredentialTypeUnknown},
+		{basic("user:password"), CredentialTypeUserBasic},
+		{basic("user:pass:word"), CredentialTypeUserBasic},
+		{"Kinvey 6a0d2c4e-session-token", CredentialTypeUserSession},
+		{"kinvey 6a0d2c4e-session-token", CredentialTypeUserSession},
+		{"Kinvey ", CredentialTypeUnknown},
+		{"Bearer token", CredentialTypeUnknown},
+	}
+
+	for _, test := range tests {
+		if got := classifyCredentials(test.authorization, appMetadata); got != test.want {
+			t.Errorf("classifyCredentials(%q) = %q, want %q", test.authorization, got, test.want)
+		}
+	}
+}
+
+func TestRequireSecurityContext(t *testing.T) {
+	appMetadata := kinveyAppMetadata{
+		ID:           "kid_abc123",
+		AppSecret:    "appsecret",
+		MasterSecret: "mastersecret",
+	}
+
+	task := &Task{AppMetadata: appMetadata}
+	task.Request.Headers = map[string]string{"authorization": basic("kid_abc123:appsecret")}
+
+	handler := RequireSecurityContext(SecurityContextMaster)(func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task) {
+		return complete.OK().Done()
+	})
+
+	modules := generateModules(task, newBaaSClient(NewOptions("", 10001, "")))
+	_, result := handler(&task.Request, NewKinveyCompletionHandler(task), modules)
+	if result.Response.Status != 401 {
+		t.Fatalf("app credentials got status %d, want 401", result.Response.Status)
+	}
+
+	task = &Task{AppMetadata: appMetadata}
+	task.Request.Headers = map[string]string{"authorization": basic("kid_abc123:mastersecret")}
+
+	modules = generateModules(task, newBaaSClient(NewOptions("", 10001, "")))
+	_, result = handler(&task.Request, NewKinveyCompletionHandler(task), modules)
+	if result.Response.Status != 200 {
+		t.Fatalf("master credentials got status %d, want 200", result.Response.Status)
+	}
+}
+
+func FuzzClassifyCredentials(f *testing.F) {
+	f.Add("Kinvey", "token")
+	f.Add("Basic", "user:password")
+	f.Add("Kinvey", "")
+	f.Add("Basic", "")
+	f.Add("Bearer", "not:a:token")
+	f.Add("Basic", "x:kid_abc123")
+	f.Add("Basic", "kid_abc123:kid_abc123")
+	f.Add("Basic", "kid_abc123:mastersecret")
+	f.Add("basic", "kid_abc123:appsecret")
+	f.Add("Basic", "kid_abc123:")
+
+	appMetadata := kinveyAppMetadata{
+		ID:           "kid_abc123",
+		AppSecret:    "appsecret",
+		MasterSecret: "mastersecret",
+	}
+
+	f.Fuzz(func(t *testing.T, scheme string, credentials string) {
+		encoded := base64.StdEncoding.EncodeToString([]byte(credentials))
+
+		for _, header := range []string{scheme + " " + encoded, scheme + " " + credentials} {
+			credentialType := classifyCredentials(header, appMetadata)
+
+			// only the exact app key and secret pairs are trusted
+			decoded := ""
+			if parts := strings.SplitN(strings.TrimSpace(header), " ", 2); len(parts) == 2 {
+				b, _ := base64.StdEncoding.DecodeString(strings.TrimSpace(parts[1]))
+				decoded = string(b)
+			}
+			if credentialType == CredentialTypeMasterSecret && decoded != "kid_abc123:mastersecret" {
+				t.Fatalf("%q was classified as the master secret", header)
+			}
+			if credentialType == CredentialTypeAppSecret && decoded != "kid_abc123:appsecret" {
+				t.Fatalf("%q was classified as the app secret", header)
+			}
+			if credentialType == CredentialTypeUserBasic && strings.HasPrefix(decoded, "kid_abc123:") {
+				t.Fatalf("%q with the app key as the username was treated as a user", header)
+			}
+			if credentialType == CredentialTypeUserSession && !strings.EqualFold(strings.Fields(header)[0], "Kinvey") {
+				t.Fatalf("%q was treated as a user session", header)
+			}
+		}
+
+		// and those pairs are always recognised
+		if strings.EqualFold(scheme, "Basic") {
+			want := map[string]string{
+				"kid_abc123:mastersecret": CredentialTypeMasterSecret,
+				"kid_abc123:appsecret":    CredentialTypeAppSecret,
+			}[credentials]
+			if got := classifyCredentials(scheme+" "+encoded, appMetadata); want != "" && got != want {
+				t.Fatalf("%s credentials %q were classified as %s", scheme, credentials, got)
+			}
+		}
+	})
+}
@@ -8,6 +8,7 @@ type kinveyOriginalRequestHeaders struct {
 	Authorization          string `json:"authorization"`
 	KinveyAPIVersion       string `json:"x-kinvey-api-version"`
 	KinveyClientAppVersion string `json:"x-kinvey-client-app-version"`
+	ForwardedFor           string `json:"x-forwarded-for"`
 }
 
 type kinveyAppMetadata struct {
@@ -49,6 +50,14 @@ type Task struct {
 	TaskID           string `json:"taskId"`
 	TaskName         string `json:"taskName"`
 	TaskType         string `json:"taskType"`
+
+	// retryable marks results that the caller may safely retry, such as
+	// tasks shed under load.
+	retryable bool
+	// credentialType, when set, is used instead of classifying the
+	// authorization header. Replays set it from the recording, whose
+	// credentials are redacted.
+	credentialType string
 }
 
 type discoveryObjects struct {
@@ -58,15 +67,18 @@ type discoveryObjects struct {
 }
 
 type dataLink struct {
-	ServiceObjects []string `json:"serviceObjects"`
+	ServiceObjects []string                         `json:"serviceObjects"`
+	Metadata       map[string]ServiceObjectMetadata `json:"metadata,omitempty"`
 }
 
 type businessLogic struct {
-	Handlers []string `json:"handlers"`
+	Handlers []string                   `json:"handlers"`
+	Metadata map[string]HandlerMetadata `json:"metadata,omitempty"`
 }
 
 type authDiscovery struct {
-	Handlers []string `json:"handlers"`
+	Handlers []string                   `json:"handlers"`
+	Metadata map[string]HandlerMetadata `json:"metadata,omitempty"`
 }
 
 type netType interface {
@@ -81,6 +93,8 @@ type RequestMetadata struct {
 	APIVersion              string                 `json:"apiVersion"`
 	Authorization           string                 `json:"authorization"`
 	ClientAppVersion        string                 `json:"clientAppVersion"`
+	ClientIP                string                 `json:"clientIp"`
+	CredentialType          string                 `json:"credentialType"`
 	CustomRequestProperties map[string]interface{} `json:"customRequestProperties"`
 	RequestID               string                 `json:"requestId"`
 	SecurityContext         string                 `json:"securityContext"`
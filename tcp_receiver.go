@@ -7,100 +7,290 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
-	"io"
 	"net"
 	"sync"
+	"time"
 )
 
+const (
+	tcpMaxTaskSize = 26214400
+	// tcpIdleTimeout closes connections that have not sent a task in this long.
+	tcpIdleTimeout  = 10 * time.Minute
+	tcpWriteTimeout = 30 * time.Second
+	// tcpMaxInFlight is the number of tasks a single connection may have
+	// running at once. Once reached, the connection is not read from until a
+	// task finishes.
+	tcpMaxInFlight = 32
+)
+
+var errTaskTooLarge = errors.New("Task exceeds the maximum size")
+
 type tcpReceiver struct {
 	server net.Listener
 
+	mu      sync.Mutex
+	conns   map[net.Conn]struct{}
+	closing bool
+
 	handlers sync.WaitGroup
 }
 
-func (rec *tcpReceiver) composeErrorReply() {
-	return
+type tcpErrorReply struct {
+	TaskID    string `json:"taskId,omitempty"`
+	IsError   bool   `json:"isError"`
+	Error     string `json:"error"`
+	Retryable bool   `json:"retryable,omitempty"`
+}
+
+func (rec *tcpReceiver) composeErrorReply(taskID string, err error) []byte {
+	reply, _ := json.Marshal(tcpErrorReply{
+		TaskID:  taskID,
+		IsError: true,
+		Error:   err.Error(),
+	})
+	return reply
+}
+
+func (rec *tcpReceiver) composeRetryableReply(task *Task) []byte {
+	kinveyError := KinveyError{}
+	json.Unmarshal(task.Response.Body, &kinveyError)
+
+	reply, _ := json.Marshal(tcpErrorReply{
+		TaskID:    task.TaskID,
+		IsError:   true,
+		Error:     kinveyError.Debug,
+		Retryable: true,
+	})
+	return reply
 }
 
 func (rec *tcpReceiver) parseTask(data []byte) (*Task, error) {
-	parsedTask := &Task{}
+	return DecodeTask(data)
+}
+
+func (rec *tcpReceiver) encodeTask(task *Task) ([]byte, error) {
+	return EncodeTask(task)
+}
+
+// taskID digs the task ID out of a task that could not be parsed, so the
+// error reply can still be matched to it.
+func taskID(data []byte) string {
+	t := struct {
+		TaskID string `json:"taskId"`
+	}{}
+	json.Unmarshal(data, &t)
+	return t.TaskID
+}
+
+// readFrame reads one newline-terminated frame, however many reads it takes.
+// Frames over max are skipped entirely so the stream stays in sync.
+func readFrame(r *bufio.Reader, max int) ([]byte, error) {
+	var frame []byte
+
+	for {
+		line, isPrefix, err := r.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(frame)+len(line) > max {
+			for isPrefix {
+				_, isPrefix, err = r.ReadLine()
+				if err != nil {
+					return nil, err
+				}
+			}
+			return nil, errTaskTooLarge
+		}
+
+		frame = append(frame, line...)
+		if !isPrefix {
+			return frame, nil
+		}
+	}
+}
+
+func (rec *tcpReceiver) processTask(data []byte, taskReceivedCallback func(task *Task) (*Task, *Task)) (reply []byte) {
+	parsedTask, err := rec.parseTask(data)
+	if err != nil {
+		return rec.composeErrorReply(taskID(data), err)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			reply = rec.composeErrorReply(parsedTask.TaskID, fmt.Errorf("Task failed: %v", r))
+		}
+	}()
+
+	taskErr, result := taskReceivedCallback(parsedTask)
+	if taskErr != nil {
+		return rec.composeErrorReply(parsedTask.TaskID, errors.New("Task failed: "+string(taskErr.Response.Body)))
+	}
+	if result == nil {
+		return rec.composeErrorReply(parsedTask.TaskID, errors.New("Task produced no result"))
+	}
+	if result.retryable {
+		return rec.composeRetryableReply(result)
+	}
 
-	err := json.Unmarshal(data, &parsedTask)
+	reply, err = rec.encodeTask(result)
 	if err != nil {
-		return nil, errors.New("Error parsing task")
+		return rec.composeErrorReply(parsedTask.TaskID, err)
 	}
 
-	return parsedTask, nil
+	return reply
 }
 
-func (rec *tcpReceiver) Start(flex Flex, taskReceivedCallback func(task *Task) (*Task, *Task), options string) error {
+func (rec *tcpReceiver) handleConnection(c net.Conn, taskReceivedCallback func(task *Task) (*Task, *Task)) {
 	healthCheckBytes := []byte(`{"healthCheck":1}`)
 
-	processTask := func(c net.Conn) {
-		defer c.Close()
+	var tasks sync.WaitGroup
+	var writeMu sync.Mutex
 
-		buf := bufio.NewReader(c)
-		for {
-			data, _, err := buf.ReadLine()
-			if err == io.EOF {
-				break
-			}
+	defer rec.untrack(c)
+	defer c.Close()
+	defer tasks.Wait()
 
-			if bytes.Equal(data, healthCheckBytes) {
-				c.Write([]byte(`{"status":"ready"}`))
-				c.Write([]byte("\n"))
-				continue
-			}
+	write := func(reply []byte) {
+		writeMu.Lock()
+		defer writeMu.Unlock()
 
-			parsedTask, err := rec.parseTask(data)
-			if err != nil {
-				fmt.Println("error parsing task")
-			}
+		c.SetWriteDeadline(time.Now().Add(tcpWriteTimeout))
+		_, err := c.Write(append(reply, '\n'))
+		if err != nil {
+			// unblock the reader so the connection is torn down
+			c.Close()
+		}
+	}
 
-			taskErr, result := taskReceivedCallback(parsedTask)
-			if taskErr != nil {
-				fmt.Println("error taskreceived callback")
-			}
+	inFlight := make(chan struct{}, tcpMaxInFlight)
+	buf := bufio.NewReader(c)
 
-			json, err := json.Marshal(result)
-			if err != nil {
-				fmt.Println("error marshall result")
-			}
+	for {
+		if !rec.extendReadDeadline(c) {
+			return
+		}
+
+		data, err := readFrame(buf, tcpMaxTaskSize)
+		if err == errTaskTooLarge {
+			write(rec.composeErrorReply("", err))
+			continue
+		}
+		if err != nil {
+			return
+		}
 
-			c.Write([]byte(string(json)))
-			c.Write([]byte("\n"))
+		data = bytes.TrimSpace(data)
+		if len(data) == 0 {
+			continue
 		}
+
+		if bytes.Equal(data, healthCheckBytes) {
+			write([]byte(`{"status":"ready"}`))
+			continue
+		}
+
+		inFlight <- struct{}{}
+		tasks.Add(1)
+		go func() {
+			defer tasks.Done()
+			defer func() { <-inFlight }()
+
+			write(rec.processTask(data, taskReceivedCallback))
+		}()
 	}
+}
 
+func (rec *tcpReceiver) Start(flex Flex, taskReceivedCallback func(task *Task) (*Task, *Task), options string) error {
 	server, err := net.Listen("tcp4", ":7000")
 	if err != nil {
 		return err
 	}
 	rec.server = server
 
-	rec.serve(processTask)
-
-	return nil
+	return rec.serve(func(c net.Conn) {
+		rec.handleConnection(c, taskReceivedCallback)
+	})
 }
 
-func (rec *tcpReceiver) serve(processTaskFunction func(c net.Conn)) {
+func (rec *tcpReceiver) serve(processTaskFunction func(c net.Conn)) error {
+	var backoff time.Duration
+
 	for {
 		conn, err := rec.server.Accept()
 		if err != nil {
-			if opErr, ok := err.(*net.OpError); ok && opErr.Timeout() {
-				continue
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+
+			// back off on errors such as running out of file descriptors
+			if backoff == 0 {
+				backoff = 5 * time.Millisecond
+			} else if backoff < time.Second {
+				backoff *= 2
 			}
 			fmt.Println("Failed to accept connection:", err.Error())
+			time.Sleep(backoff)
+			continue
+		}
+		backoff = 0
+
+		if !rec.track(conn) {
+			conn.Close()
+			continue
 		}
+
 		rec.handlers.Add(1)
 		go func() {
+			defer rec.handlers.Done()
 			processTaskFunction(conn)
-			rec.handlers.Done()
 		}()
 	}
 }
 
+func (rec *tcpReceiver) track(c net.Conn) bool {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	if rec.closing {
+		return false
+	}
+	if rec.conns == nil {
+		rec.conns = make(map[net.Conn]struct{})
+	}
+	rec.conns[c] = struct{}{}
+	return true
+}
+
+// extendReadDeadline gives c another idle timeout to send its next task,
+// unless the receiver is stopping.
+func (rec *tcpReceiver) extendReadDeadline(c net.Conn) bool {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	if rec.closing {
+		return false
+	}
+	c.SetReadDeadline(time.Now().Add(tcpIdleTimeout))
+	return true
+}
+
+func (rec *tcpReceiver) untrack(c net.Conn) {
+	rec.mu.Lock()
+	delete(rec.conns, c)
+	rec.mu.Unlock()
+}
+
+// Stop stops accepting connections and stops reading new tasks, then waits
+// for the tasks already running to reply.
 func (rec *tcpReceiver) Stop() error {
+	rec.mu.Lock()
+	rec.closing = true
+	for c := range rec.conns {
+		c.SetReadDeadline(time.Now())
+	}
+	rec.mu.Unlock()
+
 	err := rec.server.Close()
 	if err != nil {
 		return err
@@ -0,0 +1,80 @@
+package flex
+
+// Validator is implemented by request types that can check themselves after
+// decoding. Typed handlers reply with a 400 when Validate returns an error.
+type Validator interface {
+	Validate() error
+}
+
+// OnInsertTyped registers an onInsert handler that receives the request body
+// decoded into T and replies with the returned entity and a 201.
+func OnInsertTyped[T any](so ServiceObject, functionToExecute func(context *Request, entity T, modules Modules) (T, error)) error {
+	err := so.OnInsert(typedHandler(functionToExecute, (*KinveyCompletionHandler).Created))
+	if err != nil {
+		return err
+	}
+	return so.Describe("onInsert", typedMetadata[T, T]())
+}
+
+// OnUpdateTyped registers an onUpdate handler that receives the request body
+// decoded into T and replies with the returned entity.
+func OnUpdateTyped[T any](so ServiceObject, functionToExecute func(context *Request, entity T, modules Modules) (T, error)) error {
+	err := so.OnUpdate(typedHandler(functionToExecute, (*KinveyCompletionHandler).OK))
+	if err != nil {
+		return err
+	}
+	return so.Describe("onUpdate", typedMetadata[T, T]())
+}
+
+// RegisterTyped registers a function handler that receives the request body
+// decoded into In and replies with the returned Out.
+func RegisterTyped[In any, Out any](f Functions, taskName string, functionToExecute func(context *Request, input In, modules Modules) (Out, error)) {
+	f.Register(taskName, typedHandler(functionToExecute, (*KinveyCompletionHandler).OK))
+	f.Describe(taskName, typedMetadata[In, Out]())
+}
+
+// typedMetadata derives discovery schemas from a typed handler's types.
+func typedMetadata[In any, Out any]() HandlerMetadata {
+	return HandlerMetadata{
+		InputSchema:  SchemaFor[In](),
+		OutputSchema: SchemaFor[Out](),
+	}
+}
+
+// typedHandler decodes and validates the input, runs functionToExecute and
+// replies with its output using the status set by succeed.
+func typedHandler[In any, Out any](functionToExecute func(context *Request, input In, modules Modules) (Out, error), succeed func(*KinveyCompletionHandler) *KinveyCompletionHandler) HandlerFunc {
+	return func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task) {
+		var input In
+		if err := json.Unmarshal(context.Body, &input); err != nil {
+			return complete.BadRequest(err.Error()).Done()
+		}
+
+		if err := validate(&input); err != nil {
+			return complete.BadRequest(err.Error()).Done()
+		}
+
+		output, err := functionToExecute(context, input, modules)
+		if err != nil {
+			return complete.RuntimeError(err.Error()).Done()
+		}
+
+		body, err := json.Marshal(output)
+		if err != nil {
+			return complete.RuntimeError(err.Error()).Done()
+		}
+
+		return succeed(complete.SetBody(body)).Done()
+	}
+}
+
+// validate runs Validate on input whether it is declared on T or *T.
+func validate[T any](input *T) error {
+	if v, ok := any(*input).(Validator); ok {
+		return v.Validate()
+	}
+	if v, ok := any(input).(Validator); ok {
+		return v.Validate()
+	}
+	return nil
+}
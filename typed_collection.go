@@ -0,0 +1,158 @@
+package flex
+
+import (
+	"errors"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// TypedCollection wraps a Collection and decodes results into T. Types that
+// embed KinveyEntity get their _id, _acl and _kmd fields filled in, and Save
+// uses the embedded ID to choose between insert and update.
+type TypedCollection[T any] struct {
+	collection collection
+}
+
+// NewTypedCollection ...
+func NewTypedCollection[T any](dataStore DataStore, collectionName string) TypedCollection[T] {
+	return TypedCollection[T]{
+		collection: collection{
+			dataStore:      dataStore,
+			collectionName: collectionName,
+		},
+	}
+}
+
+// Find ...
+func (c TypedCollection[T]) Find(query string, options FindOptions) ([]T, error) {
+	kinveyResponse, err := c.collection.FindWithOptions(query, options)
+	if err != nil {
+		return nil, err
+	}
+
+	entities := make([]T, 0)
+	err = json.Unmarshal(kinveyResponse, &entities)
+	if err != nil {
+		return nil, err
+	}
+
+	return entities, nil
+}
+
+// FindEach decodes the result set one entity at a time and calls fn for each,
+// so large result sets are never held in memory at once. Returning an error
+// from fn stops the iteration and is returned from FindEach.
+func (c TypedCollection[T]) FindEach(query string, options FindOptions, fn func(entity T) error) error {
+	requestOptions, err := c.collection.dataStore.buildAppDataRequest(c.collection.collectionName)
+	if err != nil {
+		return err
+	}
+
+	requestOptions.Method = "GET"
+
+	options.apply(requestOptions, query)
+
+	body, err := c.collection.dataStore.makeAppDataStreamRequest(requestOptions, c.collection.collectionName)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	var fnErr error
+
+	iter := jsoniter.Parse(json, body, 4096)
+
+	// anything but an array is a Kinvey error, even with a success status
+	if next := iter.WhatIsNext(); next != jsoniter.ArrayValue {
+		if next != jsoniter.ObjectValue {
+			return errors.New("Expected an array of entities")
+		}
+		requestErr := &RequestError{StatusCode: 200}
+		iter.ReadVal(&requestErr.KinveyError)
+		if iter.Error != nil {
+			return iter.Error
+		}
+		requestErr.KinveyError.StatusCode = requestErr.StatusCode
+		return requestErr
+	}
+
+	iter.ReadArrayCB(func(iter *jsoniter.Iterator) bool {
+		var entity T
+		iter.ReadVal(&entity)
+		if iter.Error != nil {
+			return false
+		}
+
+		fnErr = fn(entity)
+		return fnErr == nil
+	})
+
+	if fnErr != nil {
+		return fnErr
+	}
+
+	return iter.Error
+}
+
+// FindOne ...
+func (c TypedCollection[T]) FindOne(query string, options FindOptions) (T, error) {
+	return c.decode(c.collection.FindOne(query, options))
+}
+
+// FindByID ...
+func (c TypedCollection[T]) FindByID(id string) (T, error) {
+	return c.decode(c.collection.FindByID(id))
+}
+
+// Insert ...
+func (c TypedCollection[T]) Insert(entity T) (T, error) {
+	return c.decode(c.collection.Insert(entity))
+}
+
+// Update ...
+func (c TypedCollection[T]) Update(id string, entity T) (T, error) {
+	return c.decode(c.collection.Update(id, entity))
+}
+
+// Save inserts entity when it has no ID and updates it otherwise. T must embed
+// KinveyEntity or otherwise implement Entity.
+func (c TypedCollection[T]) Save(entity T) (T, error) {
+	e, ok := any(&entity).(Entity)
+	if !ok {
+		e, ok = any(entity).(Entity)
+	}
+	if !ok {
+		var empty T
+		return empty, errors.New("Entity must implement GetID")
+	}
+
+	if e.GetID() != nil {
+		return c.Update(*e.GetID(), entity)
+	}
+	return c.Insert(entity)
+}
+
+// Remove ...
+func (c TypedCollection[T]) Remove(query string) (int, error) {
+	return c.collection.Remove(query)
+}
+
+// RemoveByID ...
+func (c TypedCollection[T]) RemoveByID(id string) (int, error) {
+	return c.collection.RemoveByID(id)
+}
+
+// Count ...
+func (c TypedCollection[T]) Count(query string) (int, error) {
+	return c.collection.Count(query)
+}
+
+func (c TypedCollection[T]) decode(kinveyResponse []byte, err error) (T, error) {
+	var entity T
+	if err != nil {
+		return entity, err
+	}
+
+	err = json.Unmarshal(kinveyResponse, &entity)
+	return entity, err
+}
@@ -0,0 +1,50 @@
+package flex
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTypedCollectionFindEach(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("query") {
+		case "denied":
+			w.WriteHeader(401)
+			w.Write([]byte(`{"error":"InsufficientCredentials","description":"The credentials used to authenticate this request are not authorized"}`))
+		case "error":
+			w.Write([]byte(`{"error":"KinveyInternalErrorRetry","description":"The Kinvey server encountered an unexpected error"}`))
+		default:
+			w.Write([]byte(`[{"_id":"w1","name":"one"},{"_id":"w2","name":"two"}]`))
+		}
+	}))
+	defer server.Close()
+
+	widgets := NewTypedCollection[typedWidget](testDataStore(server.URL), "widgets")
+
+	var names []string
+	err := widgets.FindEach("", FindOptions{}, func(widget typedWidget) error {
+		names = append(names, widget.Name)
+		return nil
+	})
+	if err != nil || len(names) != 2 || names[1] != "two" {
+		t.Fatalf("expected both widgets, got %v, %v", names, err)
+	}
+
+	for _, query := range []string{"denied", "error"} {
+		called := false
+		err := widgets.FindEach(query, FindOptions{}, func(widget typedWidget) error {
+			called = true
+			return nil
+		})
+
+		var requestErr *RequestError
+		if !errors.As(err, &requestErr) || requestErr.KinveyError.Error == "" {
+			t.Fatalf("%s: expected a RequestError with the Kinvey error, got %v", query, err)
+		}
+		if called {
+			t.Fatalf("%s: an error body was decoded as an entity", query)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package flex
+
+import (
+	"errors"
+	"testing"
+)
+
+type typedWidget struct {
+	Name string `json:"name"`
+}
+
+func (w typedWidget) Validate() error {
+	if w.Name == "" {
+		return errors.New("name is required")
+	}
+	return nil
+}
+
+func runFunction(ff Functions, task *Task) *Task {
+	_, result := ff.(*functions).process(task, generateModules(task, newBaaSClient(NewOptions("", 10001, ""))))
+	return result
+}
+
+func TestTypedHandlerRejectsBadBodies(t *testing.T) {
+	ff := newFunctions(newMiddlewareStack())
+
+	calls := 0
+	RegisterTyped(ff, "rename", func(context *Request, input typedWidget, modules Modules) (typedWidget, error) {
+		calls++
+		input.Name += "!"
+		return input, nil
+	})
+
+	for _, body := range []string{`not json`, `{"name":3}`, `{"name":""}`} {
+		result := runFunction(ff, &Task{TaskName: "rename", Request: Request{Body: []byte(body)}})
+		if result.Response.Status != 400 {
+			t.Errorf("body %s: expected a 400, got %d", body, result.Response.Status)
+		}
+	}
+	if calls != 0 {
+		t.Fatalf("the handler was called %d times for bad bodies", calls)
+	}
+
+	result := runFunction(ff, &Task{TaskName: "rename", Request: Request{Body: []byte(`{"name":"widget"}`)}})
+	if result.Response.Status != 200 || string(result.Response.Body) != `{"name":"widget!"}` {
+		t.Fatalf("unexpected response %d %s", result.Response.Status, result.Response.Body)
+	}
+}
+
+func TestKinveyErrorBody(t *testing.T) {
+	task := &Task{}
+	complete := NewKinveyCompletionHandler(task)
+	complete.BadRequest("details")
+
+	if task.Response.Status != 400 {
+		t.Fatalf("unexpected status %d", task.Response.Status)
+	}
+	want := `{"error":"BadRequest","description":"Unable to understand request","debug":"details"}`
+	if string(task.Response.Body) != want {
+		t.Fatalf("unexpected body %s", task.Response.Body)
+	}
+}
+
+func TestFunctionsContext(t *testing.T) {
+	ff := newFunctions(newMiddlewareStack())
+
+	var seen Request
+	ff.Register("hook", func(context *Request, complete KinveyCompletionHandler, modules Modules) (*Task, *Task) {
+		seen = *context
+		return complete.OK().Done()
+	})
+
+	task := &Task{TaskName: "hook", Request: Request{Body: []byte(`"request"`), Query: map[string][]string{"limit": {"10"}}}}
+	runFunction(ff, task)
+	if string(seen.Body) != `"request"` || seen.Query.Get("limit") != "10" {
+		t.Fatalf("unexpected context for a custom endpoint: %+v", seen)
+	}
+
+	task = &Task{TaskName: "hook", HookType: "post", Request: Request{Body: []byte(`"request"`)}, Response: Response{Body: []byte(`"response"`)}}
+	runFunction(ff, task)
+	if string(seen.Body) != `"response"` {
+		t.Fatalf("expected a post hook to see the response body, got %s", seen.Body)
+	}
+}
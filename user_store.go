@@ -1,21 +1,19 @@
 package flex
 
-import (
-	"net/http"
-)
-
 // UserStoreModule ...
 type UserStoreModule struct {
 	appMetadata    kinveyAppMetadata
 	requestContext RequestMetadata
 	taskMetadata   TaskMetadata
+	baas           *baasClient
 }
 
-func newUserStoreModule(appMetadata kinveyAppMetadata, requestMetadata RequestMetadata, taskMetadata TaskMetadata) UserStoreModule {
+func newUserStoreModule(appMetadata kinveyAppMetadata, requestMetadata RequestMetadata, taskMetadata TaskMetadata, baas *baasClient) UserStoreModule {
 	return UserStoreModule{
 		appMetadata:    appMetadata,
 		requestContext: requestMetadata,
 		taskMetadata:   taskMetadata,
+		baas:           baas,
 	}
 }
 
@@ -29,7 +27,7 @@ func (m UserStoreModule) NewUserStore(useUserContext bool) UserStore {
 	s.taskMetadata = m.taskMetadata
 	s.useBL = true
 	s.useUserContext = useUserContext
-	s.client = &http.Client{}
+	s.baas = m.baas
 	return s
 }
 
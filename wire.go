@@ -0,0 +1,101 @@
+package flex
+
+import (
+	"bytes"
+	stdjson "encoding/json"
+	"errors"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// wireTask is the JSON form of a task used by the TCP receiver and by task
+// recordings. Request and response bodies are kept raw so array and string
+// bodies survive the round trip.
+type wireTask struct {
+	*Task
+	Request  wireRequest  `json:"request"`
+	Response wireResponse `json:"response"`
+}
+
+type wireRequest struct {
+	*Request
+	Body jsoniter.RawMessage `json:"body,omitempty"`
+}
+
+type wireResponse struct {
+	*Response
+	Body jsoniter.RawMessage `json:"body,omitempty"`
+}
+
+// DecodeTask parses a task in the JSON form the TCP receiver reads.
+func DecodeTask(data []byte) (*Task, error) {
+	parsedTask := &Task{}
+
+	wire := wireTask{
+		Task:     parsedTask,
+		Request:  wireRequest{Request: &parsedTask.Request},
+		Response: wireResponse{Response: &parsedTask.Response},
+	}
+
+	err := json.Unmarshal(data, &wire)
+	if err != nil {
+		return nil, errors.New("Error parsing task")
+	}
+
+	parsedTask.Request.Body, parsedTask.Request.JSONBody = decodeBody(wire.Request.Body)
+	parsedTask.Response.Body, parsedTask.Response.JSONBody = decodeBody(wire.Response.Body)
+
+	return parsedTask, nil
+}
+
+// EncodeTask writes a task in the JSON form the TCP receiver replies with.
+func EncodeTask(task *Task) ([]byte, error) {
+	wire := wireTask{
+		Task: task,
+		Request: wireRequest{
+			Request: &task.Request,
+			Body:    encodeBody(task.Request.Body, task.Request.JSONBody),
+		},
+		Response: wireResponse{
+			Response: &task.Response,
+			Body:     encodeBody(task.Response.Body, task.Response.JSONBody),
+		},
+	}
+
+	return json.Marshal(wire)
+}
+
+func decodeBody(raw jsoniter.RawMessage) ([]byte, map[string]interface{}) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil, nil
+	}
+
+	var jsonBody map[string]interface{}
+	if raw[0] == '{' {
+		json.Unmarshal(raw, &jsonBody)
+	}
+
+	return []byte(raw), jsonBody
+}
+
+// encodeBody prefers the raw body that handlers set with SetBody, sending it
+// as a JSON string if it is not JSON itself.
+func encodeBody(body []byte, jsonBody map[string]interface{}) jsoniter.RawMessage {
+	if len(body) > 0 {
+		// jsoniter's Valid rejects top-level numbers, so the standard
+		// library's is used to keep them as numbers
+		if stdjson.Valid(body) {
+			return body
+		}
+		encoded, _ := json.Marshal(string(body))
+		return encoded
+	}
+
+	if jsonBody != nil {
+		encoded, _ := json.Marshal(jsonBody)
+		return encoded
+	}
+
+	return nil
+}